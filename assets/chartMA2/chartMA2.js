@@ -1,5 +1,6 @@
 async function updateChartMA2() {
     let symbol = document.getElementById('symbol').value;
+    let group = document.getElementById('group').value;
     let maLengthLF = document.getElementById('maLengthLF').value;
     let maLengthHF = document.getElementById('maLengthHF').value;
     let maShortShift = document.getElementById('maShortShift').value;
@@ -7,7 +8,7 @@ async function updateChartMA2() {
     let stopLossDelay = document.getElementById('stopLossDelay').value;
     let longQuickBuy = document.getElementById('longQuickBuy').checked;
     let ema = document.getElementById('ema').checked;
-    let response = await fetch('/plotly-ma2?symbol=' + symbol + '&maLengthLF=' + maLengthLF+ '&maLengthHF=' + maLengthHF + '&maShortShift=' + maShortShift + '&stopLoss=' + stopLoss + '&stopLossDelay=' + stopLossDelay + '&longQuickBuy=' + longQuickBuy + '&ema=' + ema);
+    let response = await fetch('/plotly-ma2?symbol=' + symbol + '&group=' + group + '&maLengthLF=' + maLengthLF+ '&maLengthHF=' + maLengthHF + '&maShortShift=' + maShortShift + '&stopLoss=' + stopLoss + '&stopLossDelay=' + stopLossDelay + '&longQuickBuy=' + longQuickBuy + '&ema=' + ema);
     if (response.status >= 400 && response.status < 600) {
         Plotly.deleteTraces('chartMA2Chart', [0,1,2,3,4,5]);
         tradeHistory.innerHTML = "Server replied with error; likely an invalid symbol.";
@@ -16,7 +17,7 @@ async function updateChartMA2() {
     }
     let reply = await response.json();
     Plotly.newPlot('chartMA2Chart', reply.data, reply.layout);
-    tradeHistory.innerHTML = reply.text;
+    tradeHistory.innerHTML = metricsText(reply.metrics) + reply.text;
 }
 
 async function updateValues() {
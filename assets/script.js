@@ -1,16 +1,91 @@
+// downloadData starts download jobs for the selected group (all groups when "all" is selected),
+// then polls the jobs, showing the progress, until they finish.
 async function downloadData() {
-    let response = await fetch('/downloadData')
+    let response = await fetch('/downloadData?group=' + encodeURIComponent(group.value))
 
     if (!response.ok) {
-        alert("downloadData did not successfully run and data WAS NOT loaded");
+        alert("downloadData did not successfully start and data WAS NOT loaded");
+        return;
+    }
+    let jobs = await response.json();
+    let progress = document.getElementById('downloadProgress');
+    let cancel = document.getElementById('cancelDownload');
+    progress.hidden = false;
+    cancel.hidden = false;
+    cancel.onclick = function () {
+        for (const job of jobs) {
+            fetch('/jobs/' + job.id, {method: 'DELETE'});
+        }
+    };
+
+    while (jobs.some(job => job.state == 'running')) {
+        await new Promise(resolve => setTimeout(resolve, 500));
+        jobs = await Promise.all(jobs.map(async function (job) {
+            let response = await fetch('/jobs/' + job.id);
+            return response.ok ? response.json() : job;
+        }));
+        progress.max = jobs.reduce((sum, job) => sum + job.total, 0);
+        progress.value = jobs.reduce((sum, job) => sum + job.done, 0);
+        progress.title = progress.value + ' of ' + progress.max + ' symbols';
+    }
+    progress.hidden = true;
+    cancel.hidden = true;
+
+    let failed = jobs.filter(job => job.state != 'succeeded');
+    if (failed.length > 0) {
+        let reasons = failed.map(job => job.key + ': ' + job.state + (job.error ? ', ' + job.error : ''));
+        alert("downloadData did not successfully run and data WAS NOT loaded\n" + reasons.join("\n"));
     } else {
         console.log("downloadData successful");
-        alert("downloadData successfully ran");
+        let symbolFailures = jobs.reduce((sum, job) => sum + job.failed, 0);
+        alert("downloadData successfully ran" + (symbolFailures > 0 ? ", symbols that failed: " + symbolFailures : ""));
+    }
+}
+
+// loadGroups fills the group selector with the loaded groups, then loads the symbols. Selecting
+// a group shows the symbols of that group and selects its first symbol.
+async function loadGroups() {
+    let response = await fetch('/groups')
+    let reply = await response.json();
+
+    if (!response.ok) {
+        alert("loadGroups did not successfully load groups");
+    }
+
+    // all uses the first group trading the symbol.
+    group.innerHTML = '<option value="">all</option>';
+    for (const g of reply) {
+        let option = document.createElement('option');
+        option.value = g.name;
+        option.text = g.name;
+        option.title = g.description;
+        group.appendChild(option);
+    }
+    group.onchange = async function () {
+        let reply = await loadSymbols();
+        if (reply && reply.length > 0) {
+            symbol.value = reply[0];
+            document.getElementById("process").click();
+        }
+    };
+    await loadSymbols();
+}
+
+// metricsText returns the metrics of a /plotly-* reply as text for the trade history.
+function metricsText(m) {
+    if (!m) {
+        return "";
     }
+    return "CAGR: " + m.CAGR.toFixed(2) + "% (buy/hold " + m.BuyHoldCAGR.toFixed(2) + "%), max drawdown: " + m.MaxDrawdown.toFixed(2) +
+        "% (" + m.MaxDrawdownDays + " days), volatility: " + m.Volatility.toFixed(2) + "%, Sharpe: " + m.Sharpe.toFixed(2) +
+        ", Sortino: " + m.Sortino.toFixed(2) + ", Calmar: " + m.Calmar.toFixed(2) + "\n" +
+        "trades: " + m.Trades + ", win rate: " + m.WinRate.toFixed(2) + "%, profit factor: " + m.ProfitFactor.toFixed(2) +
+        ", average win: " + m.AverageWin.toFixed(2) + "%, average loss: " + m.AverageLoss.toFixed(2) + "%, exposure: " +
+        m.Exposure.toFixed(2) + "%, turnover: " + m.Turnover.toFixed(2) + " trades/year\n\n";
 }
 
 async function loadSymbols() {
-    let response = await fetch('/symbols')
+    let response = await fetch('/symbols?group=' + encodeURIComponent(group.value))
     let reply = await response.json();
 
     if (!response.ok) {
@@ -18,4 +93,5 @@ async function loadSymbols() {
     }
 
     symbols.innerHTML =  reply.join(" ");
+    return reply;
 }
@@ -16,6 +16,7 @@ import (
 
 	"github.com/chromedp/chromedp"
 	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-quantstudio/calendar"
 	"github.com/paulfdunn/go-quantstudio/defs"
 )
 
@@ -27,10 +28,10 @@ const (
 
 var (
 	// CLI flags
-	logFilePtr *string
-	logLevel   *int
-	lp         func(level logh.LoghLevel, v ...interface{})
-	lpf        func(level logh.LoghLevel, format string, v ...interface{})
+	logFilePtr, portPtr *string
+	logLevel            *int
+	lp                  func(level logh.LoghLevel, v ...interface{})
+	lpf                 func(level logh.LoghLevel, format string, v ...interface{})
 
 	// dataDirectorySuffix is appended to the users home directory.
 	dataDirectorySuffix = filepath.Join(`tmp`, defs.AppName, appName)
@@ -53,6 +54,7 @@ func Init() {
 	logFilePtr = flag.String("logfile", "automator.log", "Name of log file in "+dataDirectory+"; blank to print logs to terminal.")
 	logLevel = flag.Int("loglevel", int(logh.Info), fmt.Sprintf("Logging level; default %d. Zero based index into: %v",
 		int(logh.Info), logh.DefaultLevels))
+	portPtr = flag.String("port", defs.GUIPort, "Address (I.E. :8080) on which go-quantstudio serves the GUI.")
 	flag.Parse()
 
 	var logFilepath string
@@ -73,7 +75,7 @@ func main() {
 	Init()
 
 	// Get the symbols that are loaded in go-quantstudio, then get screen shots for all symbols
-	screenShotUrl := fmt.Sprintf("http://%s/", "localhost:8080")
+	screenShotUrl := fmt.Sprintf("http://localhost%s/", *portPtr)
 	symbols, err := getLoadedSymbols()
 	if err != nil {
 		lpf(logh.Error, "automator could not load symbols from go-quantstudio, exiting.")
@@ -143,7 +145,7 @@ func getChromedpScreenShotsForAllSymbols(screenShotUrl string, symbols []string,
 
 // getLoadedSymbols gets the symbols that are running in go-quantstudio.
 func getLoadedSymbols() ([]string, error) {
-	resp, err := http.Get(fmt.Sprintf("http://localhost%s/symbols", defs.GUIPort))
+	resp, err := http.Get(fmt.Sprintf("http://localhost%s/symbols", *portPtr))
 	if err != nil {
 		lpf(logh.Error, "getting symbols: %s", err)
 		return nil, err
@@ -236,20 +238,10 @@ func getScreenshotForSymbol(screenShotUrl string, symbol string, quality int) {
 
 // }
 
+// waitForNextMarketClose waits until an hour after the next NYSE close, so the day's bars
+// are available. Started within the hour after a close, it waits for that close.
 func waitForNextMarketClose() {
-	now := time.Now()
-	thisAfternoon := time.Date(now.Year(), now.Month(), now.Day(), 23, 0, 0, 0, time.UTC)
-	var nextAfternoon time.Time
-	if now.Weekday() >= time.Monday && now.Weekday() <= time.Friday && now.Before(thisAfternoon) {
-		nextAfternoon = thisAfternoon
-	} else if now.Weekday() == time.Friday {
-		nextAfternoon = time.Date(now.Year(), now.Month(), now.Day()+3, 23, 0, 0, 0, time.UTC)
-	} else if now.Weekday() == time.Saturday {
-		nextAfternoon = time.Date(now.Year(), now.Month(), now.Day()+2, 23, 0, 0, 0, time.UTC)
-	} else {
-		nextAfternoon = time.Date(now.Year(), now.Month(), now.Day()+1, 23, 0, 0, 0, time.UTC)
-	}
-
+	nextAfternoon := calendar.NYSE.NextMarketClose(time.Now().Add(-time.Hour)).Add(time.Hour)
 	statusUpdateRate := time.Hour * 4
 	waitUntil(nextAfternoon, statusUpdateRate)
 }
@@ -0,0 +1,263 @@
+// Package calendar implements exchange trading calendars; weekends, holidays, and early closes.
+// NYSE and NASDAQ share the same calendar. Holidays are computed from the rules in effect
+// since 2022, with the start year of each holiday, plus special closures since 1990.
+package calendar
+
+import (
+	"sync"
+	"time"
+
+	// Embed the timezone database so the exchange location is always available.
+	_ "time/tzdata"
+)
+
+// Calendar is the trading calendar for an exchange. Dates passed to Calendar methods are
+// converted to Location before the calendar day is determined.
+type Calendar struct {
+	Name     string
+	Location *time.Location
+	// Open and Close are the regular session times, and EarlyClose the close time on early
+	// close days, as the duration after midnight in Location.
+	Open       time.Duration
+	Close      time.Duration
+	EarlyClose time.Duration
+
+	// holidays and earlyCloses are caches of the computed dates for each year.
+	mutex       sync.Mutex
+	holidays    map[int]map[string]bool
+	earlyCloses map[int]map[string]bool
+}
+
+const (
+	dateFormat = "2006-01-02"
+)
+
+var (
+	NYSE   = New("NYSE")
+	NASDAQ = New("NASDAQ")
+
+	// specialClosures are days the NYSE closed for reasons other than a regular holiday.
+	specialClosures = []string{
+		"1994-04-27",                                           // Nixon funeral
+		"2001-09-11", "2001-09-12", "2001-09-13", "2001-09-14", // September 11
+		"2004-06-11",               // Reagan funeral
+		"2007-01-02",               // Ford funeral
+		"2012-10-29", "2012-10-30", // Hurricane Sandy
+		"2018-12-05", // G.H.W. Bush funeral
+		"2025-01-09", // Carter funeral
+	}
+)
+
+// New is a factory for Calendar using the NYSE/NASDAQ holidays and session times.
+func New(name string) *Calendar {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		// Not possible with the embedded timezone database.
+		panic(err)
+	}
+	return &Calendar{Name: name, Location: location,
+		Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour, EarlyClose: 13 * time.Hour,
+		holidays: make(map[int]map[string]bool), earlyCloses: make(map[int]map[string]bool)}
+}
+
+// IsEarlyClose returns true if date is a trading day that closes at EarlyClose.
+func (cal *Calendar) IsEarlyClose(date time.Time) bool {
+	d := date.In(cal.Location)
+	_, earlyCloses := cal.year(d.Year())
+	return earlyCloses[d.Format(dateFormat)] && cal.IsTradingDay(d)
+}
+
+// IsHoliday returns true if the exchange is closed on date, which is not a weekend.
+func (cal *Calendar) IsHoliday(date time.Time) bool {
+	d := date.In(cal.Location)
+	holidays, _ := cal.year(d.Year())
+	return holidays[d.Format(dateFormat)]
+}
+
+// IsTradingDay returns true if the exchange is open on date.
+func (cal *Calendar) IsTradingDay(date time.Time) bool {
+	d := date.In(cal.Location)
+	if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		return false
+	}
+	return !cal.IsHoliday(d)
+}
+
+// MarketClose returns the time the exchange closes on date, or the zero time if date is not
+// a trading day.
+func (cal *Calendar) MarketClose(date time.Time) time.Time {
+	if !cal.IsTradingDay(date) {
+		return time.Time{}
+	}
+	if cal.IsEarlyClose(date) {
+		return cal.midnight(date).Add(cal.EarlyClose)
+	}
+	return cal.midnight(date).Add(cal.Close)
+}
+
+// MarketOpen returns the time the exchange opens on date, or the zero time if date is not
+// a trading day.
+func (cal *Calendar) MarketOpen(date time.Time) time.Time {
+	if !cal.IsTradingDay(date) {
+		return time.Time{}
+	}
+	return cal.midnight(date).Add(cal.Open)
+}
+
+// MissingTradingDays returns the trading days after prior and before next; I.E. the days for
+// which a bar is missing when prior and next are consecutive bars.
+func (cal *Calendar) MissingTradingDays(prior time.Time, next time.Time) []time.Time {
+	var missing []time.Time
+	last := cal.midnight(next)
+	for d := cal.NextTradingDay(prior); d.Before(last); d = cal.NextTradingDay(d) {
+		missing = append(missing, d)
+	}
+	return missing
+}
+
+// NextMarketClose returns the first market close after t.
+func (cal *Calendar) NextMarketClose(t time.Time) time.Time {
+	if close := cal.MarketClose(t); close.After(t) {
+		return close
+	}
+	return cal.MarketClose(cal.NextTradingDay(t))
+}
+
+// NextTradingDay returns midnight (in Location) of the first trading day after date.
+func (cal *Calendar) NextTradingDay(date time.Time) time.Time {
+	d := cal.midnight(date)
+	for {
+		d = time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, cal.Location)
+		if cal.IsTradingDay(d) {
+			return d
+		}
+	}
+}
+
+// TradingDays returns midnight (in Location) of every trading day from start to end, inclusive.
+func (cal *Calendar) TradingDays(start time.Time, end time.Time) []time.Time {
+	var days []time.Time
+	d := cal.midnight(start)
+	if !cal.IsTradingDay(d) {
+		d = cal.NextTradingDay(d)
+	}
+	last := cal.midnight(end)
+	for ; !d.After(last); d = cal.NextTradingDay(d) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// midnight returns the start of the calendar day of date in Location.
+func (cal *Calendar) midnight(date time.Time) time.Time {
+	d := date.In(cal.Location)
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, cal.Location)
+}
+
+// year returns the holidays and early closes for year, computing them on first use.
+func (cal *Calendar) year(year int) (holidays map[string]bool, earlyCloses map[string]bool) {
+	cal.mutex.Lock()
+	defer cal.mutex.Unlock()
+	if holidays, ok := cal.holidays[year]; ok {
+		return holidays, cal.earlyCloses[year]
+	}
+
+	holidays = make(map[string]bool)
+	add := func(t time.Time) {
+		if t.Year() == year {
+			holidays[t.Format(dateFormat)] = true
+		}
+	}
+	// New Years Day is not observed on the prior Friday when on a Saturday.
+	newYears := date(year, time.January, 1)
+	if newYears.Weekday() == time.Sunday {
+		newYears = newYears.AddDate(0, 0, 1)
+	}
+	if newYears.Weekday() != time.Saturday {
+		add(newYears)
+	}
+	if year >= 1998 {
+		add(nthWeekday(year, time.January, time.Monday, 3))
+	}
+	add(nthWeekday(year, time.February, time.Monday, 3))
+	add(easter(year).AddDate(0, 0, -2))
+	add(lastWeekday(year, time.May, time.Monday))
+	if year >= 2022 {
+		add(observed(date(year, time.June, 19)))
+	}
+	add(observed(date(year, time.July, 4)))
+	add(nthWeekday(year, time.September, time.Monday, 1))
+	add(nthWeekday(year, time.November, time.Thursday, 4))
+	add(observed(date(year, time.December, 25)))
+	for _, closure := range specialClosures {
+		t, _ := time.Parse(dateFormat, closure)
+		add(t)
+	}
+
+	earlyCloses = make(map[string]bool)
+	july3 := date(year, time.July, 3)
+	if july3.Weekday() >= time.Monday && july3.Weekday() <= time.Thursday {
+		earlyCloses[july3.Format(dateFormat)] = true
+	}
+	earlyCloses[nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1).Format(dateFormat)] = true
+	christmasEve := date(year, time.December, 24)
+	if christmasEve.Weekday() >= time.Monday && christmasEve.Weekday() <= time.Thursday {
+		earlyCloses[christmasEve.Format(dateFormat)] = true
+	}
+
+	cal.holidays[year] = holidays
+	cal.earlyCloses[year] = earlyCloses
+	return holidays, earlyCloses
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// easter returns Easter Sunday for year, using the anonymous Gregorian algorithm.
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := ((h + l - 7*m + 114) % 31) + 1
+	return date(year, time.Month(month), day)
+}
+
+// lastWeekday returns the last weekday in month.
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	t := date(year, month+1, 1).AddDate(0, 0, -1)
+	for t.Weekday() != weekday {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// nthWeekday returns the nth (1 based) weekday in month.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	t := date(year, month, 1)
+	for t.Weekday() != weekday {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.AddDate(0, 0, 7*(n-1))
+}
+
+// observed moves a holiday on a Saturday to the prior Friday, and on a Sunday to the next Monday.
+func observed(t time.Time) time.Time {
+	switch t.Weekday() {
+	case time.Saturday:
+		return t.AddDate(0, 0, -1)
+	case time.Sunday:
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
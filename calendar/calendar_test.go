@@ -0,0 +1,61 @@
+package calendar
+
+import (
+	"fmt"
+	"time"
+)
+
+func ExampleCalendar_IsTradingDay() {
+	for _, d := range []string{"2024-01-01", "2024-01-15", "2024-03-29", "2024-06-19", "2024-07-03", "2024-07-05",
+		"2024-11-29", "2024-12-24", "2024-12-25", "2021-12-31", "2022-01-17", "2022-06-20", "2022-12-26", "2012-10-29"} {
+		t, _ := time.ParseInLocation(dateFormat, d, NYSE.Location)
+		fmt.Printf("%s %-9s trading day: %-5t early close: %t\n", d, t.Weekday(), NYSE.IsTradingDay(t), NYSE.IsEarlyClose(t))
+	}
+
+	// Output:
+	// 2024-01-01 Monday    trading day: false early close: false
+	// 2024-01-15 Monday    trading day: false early close: false
+	// 2024-03-29 Friday    trading day: false early close: false
+	// 2024-06-19 Wednesday trading day: false early close: false
+	// 2024-07-03 Wednesday trading day: true  early close: true
+	// 2024-07-05 Friday    trading day: true  early close: false
+	// 2024-11-29 Friday    trading day: true  early close: true
+	// 2024-12-24 Tuesday   trading day: true  early close: true
+	// 2024-12-25 Wednesday trading day: false early close: false
+	// 2021-12-31 Friday    trading day: true  early close: false
+	// 2022-01-17 Monday    trading day: false early close: false
+	// 2022-06-20 Monday    trading day: false early close: false
+	// 2022-12-26 Monday    trading day: false early close: false
+	// 2012-10-29 Monday    trading day: false early close: false
+}
+
+func ExampleCalendar_NextMarketClose() {
+	// Before the close, after the close on a Friday, and the day before Thanksgiving.
+	for _, t := range []time.Time{
+		time.Date(2024, time.March, 7, 15, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 8, 22, 0, 0, 0, time.UTC),
+		time.Date(2024, time.November, 27, 23, 0, 0, 0, time.UTC),
+	} {
+		fmt.Printf("%s\n", NYSE.NextMarketClose(t).UTC().Format(time.RFC3339))
+	}
+
+	// Output:
+	// 2024-03-07T21:00:00Z
+	// 2024-03-11T20:00:00Z
+	// 2024-11-29T18:00:00Z
+}
+
+func ExampleCalendar_MissingTradingDays() {
+	prior := time.Date(2024, time.March, 27, 14, 30, 0, 0, time.UTC)
+	next := time.Date(2024, time.April, 3, 14, 30, 0, 0, time.UTC)
+	for _, d := range NYSE.MissingTradingDays(prior, next) {
+		fmt.Printf("%s\n", d.Format(dateFormat))
+	}
+	fmt.Printf("%d\n", len(NYSE.TradingDays(time.Date(2024, time.January, 1, 0, 0, 0, 0, NYSE.Location), time.Date(2024, time.December, 31, 0, 0, 0, 0, NYSE.Location))))
+
+	// Output:
+	// 2024-03-28
+	// 2024-04-01
+	// 2024-04-02
+	// 252
+}
@@ -2,6 +2,7 @@ package defs
 
 const (
 	AppName = "go-quantstudio"
+	// GUIPort is the default address of the GUI; see the port flag.
 	GUIPort = ":8080"
 
 	// CHANGE DEFAULTS HERE AND IN HTML FILES.
@@ -27,71 +28,7 @@ const (
 	MA2LongQuickBuy      = true
 	MA2EMA               = false
 
-	// Symbols being added for use in analysis. These symbols will always be downloaded, but only
-	// used as inputs for quantitative analysis with TradingSymbolsDefault
-	//
-	// ^fvx Treasury Yield 5 Years
-	// ^tnx Treasury Yield 10 Years
-	// AnalysisSymbols = "^fvx,^tnx"
-	AnalysisSymbols = ""
-	//
-	// Symbols for trading
-	//
-	// DIA (0.16%) SPDR Dow Jones Industrial Average ETF Trust
-	// IAU (0.25%) iShares Gold Trust
-	// IDEV (0.04%) iShares Core MSCI International Developed Markets ETF (large-, mid- and small-capitalization developed market equities, excluding the United States)
-	// IEFA (0.07%) iShares Core MSCI EAFE ETF large-, mid- and small-capitalization developed market equities, excluding the U.S. and Canada. (Not currency hedged; HEFA is the equivalent ETF hedged in USD.)
-	// INTF (0.16%) ISHARES INTERNATIONAL EQUITY FACTOR ETF - track the investment results of the STOXX International Equity Factor Index
-	// PSQ (0.95%) ProShares Short QQQ
-	// QQQ (0.2%) Invesco QQQ Trust Series 1 - lower spreads and most liquidity Nasdaq 100 ETF
-	// QQQM (0.15%) Invesco Nasdaq 100 ETF - similar to QQQ, but lower cost, less liquidity, less history
-	// RSP (0.2%) Invesco S&P 500 Eql Wght ETF
-	// SPY (0.09%) SPDR S&P 500 ETF Trust
-	// VGT (0.09%) Vanguard Information Technology Index Fund ETF Shares - QQQ like
-	// VT (0.06%) Vanguard Total World Stock ETF
-	// Double ETFs below
-	// DDM ProShares Ultra Dow30
-	// QLD ProShares Ultra QQQ
-	// SSO ProShares Ultra S&P500
-	// Triple ETFs below
-	// TQQQ ProShares UltraPro QQQ
-	TradingSymbolsDefault = "^tnx,dia,iau,idev,iefa,intf,psq,qqq,qqqm,rsp,spy,vgt,vt,ddm,qld,sso,tqqq,flot,stip,vcit,vcsh,emb,emhy,bnd,hdv,schd,vym,hdef,shyg"
-	// testing only
-	// TradingSymbolsDefault = "dia,ioo,qqq,spy,vt"
+	// Symbols, and the notes kept about them, are in universe files; the default universe is
+	// universe/default.yaml.
+	UniverseGroupsDefault = "etfs,bonds,income"
 )
-
-// Bond options for when stocks aren't so great.
-
-// Ultrashort Bond
-// FLOT ISHARES FLOATING RATE BOND ETF
-//
-// Short-Term Inflation Protected Bond
-// STIP ISHARES 0-5 YEAR TIPS BOND ETF
-//
-// Corporate Bond
-// VCIT VANGUARD INTERMEDIATE-TERM (5-10 years) CORPORATE BOND ETF
-// VCSH VANGUARD SHORT-TERM (1-5 years) CORPORATE BOND ETF
-//
-// Emerging Markets Bond
-// EMB iShares JPM USD Emerging Market Bond
-// EMHY ISHARES J.P. MORGAN EM HIGH YIELD BOND ETF
-//
-// Total bond market
-// BND Vanguard Total Bond Market ETF
-//
-// Large Value
-// HDV ISHARES CORE HIGH DIVIDEND ETF (~3.5% yield)
-// SCHD SCHWAB US DIVIDEND EQUITY ETF (~3.8% yield)
-//
-// High dividend yield
-// VYM VANGUARD HIGH DIVIDEND YIELD INDEX ETF
-// HDEF Xtrackers MSCI EAFE High Dividend Yield
-// SHYG ISHARES 0-5 YEAR HIGH YIELD CORPORATE BOND ETF
-//
-// E*TRADE Prebuilt Income Portfolios
-// VYM Vanguard High Dividend ETF
-// HDEF Xtrackers MSCI EAFE High Dividend Yield
-// BND Vanguard Total Bond Market ETF
-// VCIT Vanguard Intermediate Term (5-10 years) Corporate Bond
-// EMB iShares JPM USD Emerging Market Bond
-// vym hdef bnd vcit emb
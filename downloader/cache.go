@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache holds the current Group for each group name, for use by concurrent readers (I.E. HTTP
+// handlers). Set replaces a Group atomically; readers holding the prior CacheEntry keep using
+// it. Groups in a Cache are shared, so they must not be modified after Set.
+type Cache struct {
+	mutex   sync.RWMutex
+	entries map[string]CacheEntry
+	version uint64
+}
+
+// CacheEntry is a Group in a Cache, with the version and time it was set. Versions increase
+// with every Set, across all names in the Cache.
+type CacheEntry struct {
+	Group   *Group
+	Version uint64
+	Updated time.Time
+}
+
+// NewCache is a factory for Cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]CacheEntry)}
+}
+
+// Get returns the CacheEntry for name; false if no Group has been set for name.
+func (c *Cache) Get(name string) (CacheEntry, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry, ok := c.entries[name]
+	return entry, ok
+}
+
+// Names returns the names with a Group, sorted.
+func (c *Cache) Names() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set makes group the current Group for name, and returns the new CacheEntry. A nil group is
+// ignored, so a failed download does not replace data that loaded, and the current CacheEntry
+// (if any) is returned.
+func (c *Cache) Set(name string, group *Group) CacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if group == nil {
+		return c.entries[name]
+	}
+	c.version++
+	entry := CacheEntry{Group: group, Version: c.version, Updated: time.Now()}
+	c.entries[name] = entry
+	return entry
+}
@@ -0,0 +1,41 @@
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SetCash sets Cash on every Issue in grp from the Issue symbol in grp: a money-market ETF
+// (I.E. sgov), or, with yield true, a yield index (I.E. ^irx) quoted as an annual yield in
+// percent. Cash[i] is the value on Date[i] of cash invested on the first bar of symbol; the
+// value of the most recent bar of symbol on or before the day is used. The return of cash
+// between two bars is the ratio of Cash on the two bars.
+func (grp *Group) SetCash(symbol string, yield bool) error {
+	var cash *Issue
+	for i := range grp.Issues {
+		if strings.EqualFold(grp.Issues[i].Symbol, symbol) {
+			cash = &grp.Issues[i]
+		}
+	}
+	if cash == nil || len(cash.DatasetAsColumns.Date) == 0 {
+		return fmt.Errorf("no cash data, symbol: %s, group: %s", symbol, grp.Name)
+	}
+
+	index := DatasetAsColumns{Date: cash.DatasetAsColumns.Date, AdjClose: cash.DatasetAsColumns.AdjClose}
+	if yield {
+		// A yield earns the rate of the prior bar for the days to the next bar.
+		index.AdjClose = make([]float64, len(index.Date))
+		index.AdjClose[0] = 1
+		for i := 1; i < len(index.Date); i++ {
+			years := index.Date[i].Sub(index.Date[i-1]).Hours() / (24 * 365)
+			index.AdjClose[i] = index.AdjClose[i-1] * math.Pow(1+cash.DatasetAsColumns.Close[i-1]/100, years)
+		}
+	}
+	for i := range grp.Issues {
+		iss := &grp.Issues[i]
+		iss.Cash = make([]float64, len(iss.DatasetAsColumns.Date))
+		alignRates(iss.Cash, iss.DatasetAsColumns, index, 1)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-helper/neth/v2/httph"
+)
+
+// RetryPolicy controls how collectGroup retries a URL that failed. Network errors, HTTP 429
+// (Too Many Requests), and HTTP 5xx are retried; other HTTP errors are not.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of requests per URL; values < 1 make a single request.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; the wait doubles with each retry
+	// up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff that is randomized, to keep retries from
+	// synchronizing; I.E. 0.2 waits between 0.8 and 1.2 times the backoff.
+	Jitter float64
+}
+
+// RateLimiter is a token bucket limiting the rate of requests. Tokens are added at Rate per
+// second, up to Burst tokens, and each request takes one token.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Failure is a symbol for which no data was loaded, and the reason.
+type Failure struct {
+	Symbol string
+	Error  string
+}
+
+var (
+	// Retry is the RetryPolicy used by collectGroup.
+	Retry = RetryPolicy{MaxAttempts: 4, InitialBackoff: 2 * time.Second, MaxBackoff: time.Minute, Jitter: 0.2}
+	// RateLimit, when not nil, limits the rate of requests made by collectGroup.
+	RateLimit = NewRateLimiter(2, 1)
+)
+
+// NewRateLimiter is a factory for RateLimiter; rate <= 0 does not limit requests. The bucket
+// starts full.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{Rate: rate, Burst: burst, tokens: float64(burst)}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if rl == nil || rl.Rate <= 0 || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for {
+		wait := rl.take(time.Now())
+		if wait == 0 {
+			return nil
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// take removes a token and returns 0, or returns the time until a token is available.
+func (rl *RateLimiter) take(now time.Time) time.Duration {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	if !rl.last.IsZero() {
+		rl.tokens = math.Min(float64(rl.Burst), rl.tokens+now.Sub(rl.last).Seconds()*rl.Rate)
+	}
+	rl.last = now
+	if rl.tokens >= 1 {
+		rl.tokens--
+		return 0
+	}
+	return time.Duration((1 - rl.tokens) / rl.Rate * float64(time.Second))
+}
+
+// backoff returns the wait before retry number attempt (1 based), including jitter.
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	wait := rp.InitialBackoff
+	for i := 1; i < attempt && wait < rp.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if rp.MaxBackoff > 0 && wait > rp.MaxBackoff {
+		wait = rp.MaxBackoff
+	}
+	if rp.Jitter > 0 {
+		wait = time.Duration(float64(wait) * (1 + rp.Jitter*(2*rand.Float64()-1)))
+	}
+	return wait
+}
+
+// collectGroup requests all urls, using Threads parallel requests, RateLimit, and Retry.
+// Results are returned in urls order; URLs that failed after all retries have Err set.
+// The ProgressFunc of ctx (see WithProgress) is called with the symbol from urlSymbolMap as
+// each URL completes. Once ctx is done the remaining URLs are not requested, and have Err set.
+func collectGroup(ctx context.Context, urls []string, urlSymbolMap map[string]string) []httph.URLCollectionData {
+	// Get data for all symbols.
+	headers := []httph.Header{
+		{Key: "User-Agent", Value: "Golang_Spider_Bot/3.0"},
+	}
+	urlData := make([]httph.URLCollectionData, len(urls))
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range tasks {
+				urlData[index] = collectURL(ctx, urls[index], headers)
+				if progress := progressFrom(ctx); progress != nil {
+					progress(urlSymbolMap[BaseURL(urls[index])], urlData[index].Err)
+				}
+			}
+		}()
+	}
+	for i := range urls {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+	return urlData
+}
+
+// collectURL requests url, retrying using Retry. The Response is set to nil as it cannot be
+// Marshalled; set to nil to prevent errors when saving the data and also generating SA1026
+// lint error. Err is set for HTTP errors, so callers only need to check Err.
+func collectURL(ctx context.Context, url string, headers []httph.Header) httph.URLCollectionData {
+	ucd := httph.URLCollectionData{URL: url}
+	for attempt := 1; ; attempt++ {
+		if err := RateLimit.Wait(ctx); err != nil {
+			ucd.Err = err
+			return ucd
+		}
+		var resp *http.Response
+		ucd.Bytes, resp, ucd.Err = httph.CollectURL(url, URLCollectionTimeout, http.MethodGet, headers)
+		retry := ucd.Err != nil
+		var retryAfter time.Duration
+		if ucd.Err == nil && resp != nil && resp.StatusCode != http.StatusOK {
+			ucd.Err = fmt.Errorf("HTTP status: %s", resp.Status)
+			retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				retryAfter = time.Duration(seconds) * time.Second
+			}
+		}
+		if ucd.Err == nil || !retry || attempt >= Retry.MaxAttempts {
+			if ucd.Err != nil {
+				lpf(logh.Error, "collecting URL failed, attempts: %d, URL: %s, error: %+v", attempt, url, ucd.Err)
+			}
+			return ucd
+		}
+
+		// Retry-After is honored up to MaxBackoff, so a server cannot stall the download.
+		wait := Retry.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+			if Retry.MaxBackoff > 0 && wait > Retry.MaxBackoff {
+				wait = Retry.MaxBackoff
+			}
+		}
+		lpf(logh.Warning, "collecting URL failed, retry in: %s, URL: %s, error: %+v", wait, url, ucd.Err)
+		if err := sleep(ctx, wait); err != nil {
+			ucd.Err = err
+			return ucd
+		}
+	}
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// urlCollectionDataToGroupPartial converts each element of urlData to a Group separately, so
+// a failure only drops the symbol that failed; failed symbols are recorded in Group.Failures.
+// An error is only returned when no symbols were loaded.
+func urlCollectionDataToGroupPartial(urlData []httph.URLCollectionData, urlSymbolMap map[string]string, name string,
+	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
+	group := &Group{Name: name}
+	for _, ucd := range urlData {
+		symbol := urlSymbolMap[BaseURL(ucd.URL)]
+		if ucd.Err != nil {
+			group.Failures = append(group.Failures, Failure{Symbol: symbol, Error: ucd.Err.Error()})
+			continue
+		}
+		symbolGroup, err := callbackURLCollectionDataToGroup([]httph.URLCollectionData{ucd}, urlSymbolMap, name)
+		if err != nil {
+			group.Failures = append(group.Failures, Failure{Symbol: symbol, Error: err.Error()})
+			continue
+		}
+		group.Issues = append(group.Issues, symbolGroup.Issues...)
+	}
+
+	if len(group.Failures) > 0 {
+		lpf(logh.Warning, "Group loaded with failures; group: %s, failures: %+v", name, group.Failures)
+	}
+	if len(group.Issues) == 0 && len(urlData) > 0 {
+		err := fmt.Errorf("no data loaded for group: %s, failures: %+v", name, group.Failures)
+		lpf(logh.Error, "%+v", err)
+		return nil, err
+	}
+	return group, nil
+}
@@ -0,0 +1,290 @@
+// Package csvDirectory implements a price source that builds a Group from a directory of
+// per-symbol OHLCV CSV files; I.E. broker exports or vendor files. By default the files use the
+// same columns written by Group.SaveCSV; use Options to map other layouts.
+package csvDirectory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-helper/mathh/v2"
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/keyValue"
+)
+
+// Options control where the files are found and how they are parsed.
+type Options struct {
+	// Directory holding one file per symbol, named <symbol><Extension>. When empty, the
+	// dataFilePath passed to NewGroup is used as the directory.
+	Directory string
+	// Extension of the files; dl.CSVExtension when empty.
+	Extension string
+	// Columns maps a Data field name (Date, Open, High, Low, Close, Volume, AdjOpen, AdjHigh,
+	// AdjLow, AdjClose, AdjVolume) to the header name used in the file. Fields that are not
+	// in the map use the field name as the header name. Header names are case insensitive.
+	Columns map[string]string
+	// Comma is the field delimiter; ',' when zero.
+	Comma rune
+	// DateFormat is used to parse the Date column; dl.BarInterval.DateFormat() when empty.
+	DateFormat string
+}
+
+// Source implements dl.Downloader and is registered with the downloader as SourceName.
+// Options of a Source with no Options set are Defaults.
+type Source struct {
+	Options Options
+}
+
+const (
+	// SourceName is the name used to register this source with the downloader.
+	SourceName = "csvDirectory"
+)
+
+var (
+	appName string
+	// lp      func(level logh.LoghLevel, v ...interface{})
+	lpf func(level logh.LoghLevel, format string, v ...interface{})
+
+	// Defaults are the Options of the registered Source; see ParseOptions.
+	Defaults Options
+
+	// fields are the Data fields that can be loaded from a file, in Data order.
+	fields = []string{"Date", "Open", "High", "Low", "Close", "Volume", "AdjOpen", "AdjHigh", "AdjLow", "AdjClose", "AdjVolume"}
+)
+
+func Init(appNameInit string) {
+	appName = appNameInit
+	// lp = logh.Map[appName].Println
+	lpf = logh.Map[appName].Printf
+
+	if err := dl.Register(SourceName, Source{}); err != nil {
+		lpf(logh.Warning, "registering source: %+v", err)
+	}
+}
+
+// NewGroup implements dl.Downloader. The files are always read; liveData is ignored as the
+// files are the only copy of the data. The files must hold bars of dl.BarInterval.
+func (src Source) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	src.Options = src.options()
+	directory := src.Options.Directory
+	if directory == "" {
+		directory = dataFilePath
+	}
+	extension := src.Options.Extension
+	if extension == "" {
+		extension = dl.CSVExtension
+	}
+
+	group := &dl.Group{Name: name}
+	for _, symbol := range symbols {
+		filePath := filepath.Join(directory, symbol+extension)
+		issue, err := src.loadIssue(symbol, filePath)
+		if err != nil {
+			return nil, err
+		}
+		group.Issues = append(group.Issues, issue)
+
+		dac := issue.DatasetAsColumns
+		lpf(logh.Info, "Issue loaded; symbol:%5s, StartDate:%s, EndDate:%s, data points:%d",
+			issue.Symbol, dac.Date[0].Format(issue.Interval.DateFormat()), dac.Date[len(dac.Date)-1].Format(issue.Interval.DateFormat()), len(dac.Date))
+	}
+
+	return group, nil
+}
+
+// ParseOptions returns base changed by spec, a comma separated list of key=value pairs; I.E.
+// "date=Trade Date,close=Last,comma=;,dateFormat=01/02/2006". The keys are directory,
+// extension, comma (a single character, or tab), dateFormat (a Go time layout), and the Data
+// field names (date, open, high, low, close, volume, adjOpen, adjHigh, adjLow, adjClose, and
+// adjVolume) to map a field to the header name used in the files.
+func ParseOptions(spec string, base Options) (Options, error) {
+	opts := base
+	opts.Columns = make(map[string]string, len(base.Columns))
+	for field, column := range base.Columns {
+		opts.Columns[field] = column
+	}
+	err := keyValue.Parse(spec, func(key string, value string) error {
+		switch key {
+		case "directory":
+			opts.Directory = value
+		case "extension":
+			opts.Extension = value
+		case "comma":
+			if strings.EqualFold(value, "tab") {
+				value = "\t"
+			}
+			if utf8.RuneCountInString(value) != 1 {
+				return fmt.Errorf("comma must be one character")
+			}
+			opts.Comma, _ = utf8.DecodeRuneInString(value)
+		case "dateformat":
+			opts.DateFormat = value
+		default:
+			for _, field := range fields {
+				if strings.EqualFold(key, field) {
+					opts.Columns[field] = value
+					return nil
+				}
+			}
+			return fmt.Errorf("unknown key")
+		}
+		return nil
+	})
+	if err != nil {
+		return base, fmt.Errorf("invalid option: %w", err)
+	}
+	return opts, nil
+}
+
+// options returns the Options of src; Defaults when no Options are set.
+func (src Source) options() Options {
+	opts := src.Options
+	if opts.Directory == "" && opts.Extension == "" && len(opts.Columns) == 0 && opts.Comma == 0 && opts.DateFormat == "" {
+		return Defaults
+	}
+	return opts
+}
+
+// headerIndices maps the Data field names to the column index in header. Date and Close
+// are required; all other fields are optional.
+func (src Source) headerIndices(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, v := range header {
+		columns[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+
+	indices := make(map[string]int)
+	for _, field := range fields {
+		column := field
+		if mapped, ok := src.Options.Columns[field]; ok {
+			column = mapped
+		}
+		if i, ok := columns[strings.ToLower(column)]; ok {
+			indices[field] = i
+		}
+	}
+
+	for _, required := range []string{"Date", "Close"} {
+		if _, ok := indices[required]; !ok {
+			return nil, fmt.Errorf("no %s column in header: %+v", required, header)
+		}
+	}
+	return indices, nil
+}
+
+// loadIssue reads filePath and returns the data as an Issue, in Date ascending order.
+func (src Source) loadIssue(symbol string, filePath string) (dl.Issue, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		lpf(logh.Error, "opening CSV file, symbol: %s, error: %+v", symbol, err)
+		return dl.Issue{}, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	if src.Options.Comma != 0 {
+		r.Comma = src.Options.Comma
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		lpf(logh.Error, "reading CSV file, symbol: %s, error: %+v", symbol, err)
+		return dl.Issue{}, err
+	}
+	if len(records) < 2 {
+		err := fmt.Errorf("no data in CSV file, symbol: %s, file: %s", symbol, filePath)
+		lpf(logh.Error, "%+v", err)
+		return dl.Issue{}, err
+	}
+
+	indices, err := src.headerIndices(records[0])
+	if err != nil {
+		lpf(logh.Error, "symbol: %s, %+v", symbol, err)
+		return dl.Issue{}, err
+	}
+	dateFormat := src.Options.DateFormat
+	if dateFormat == "" {
+		dateFormat = dl.BarInterval.DateFormat()
+	}
+
+	dataset := make([]dl.Data, 0, len(records)-1)
+	for _, record := range records[1:] {
+		data, ok, err := recordToData(record, indices, dateFormat, symbol)
+		if err != nil {
+			return dl.Issue{}, err
+		}
+		if !ok {
+			continue
+		}
+		dataset = append(dataset, data)
+	}
+	sort.SliceStable(dataset, func(i, j int) bool { return dataset[i].Date.Before(dataset[j].Date) })
+	if len(dataset) == 0 {
+		err := fmt.Errorf("no valid records in CSV file, symbol: %s, file: %s", symbol, filePath)
+		lpf(logh.Error, "%+v", err)
+		return dl.Issue{}, err
+	}
+
+	issue := dl.Issue{Symbol: symbol, URL: filePath, Interval: dl.BarInterval, Dataset: dataset}
+	issue.DatasetAsColumns = issue.ToDatasetAsColumns()
+	// Dataset is only an intermediate; other sources only populate DatasetAsColumns.
+	issue.Dataset = nil
+	return issue, nil
+}
+
+// recordToData converts a single record to Data. Records containing null values are skipped
+// (ok == false). Adjusted values that are not in the file are derived from the ratio of AdjClose
+// to Close; when AdjClose is not in the file, Close is used.
+func recordToData(record []string, indices map[string]int, dateFormat string, symbol string) (data dl.Data, ok bool, err error) {
+	data.Date, err = time.Parse(dateFormat, strings.TrimSpace(record[indices["Date"]]))
+	if err != nil {
+		err := fmt.Errorf("parsing date, symbol: %s, record: %+v, error: %v", symbol, record, err)
+		lpf(logh.Error, "%+v", err)
+		return dl.Data{}, false, err
+	}
+
+	// Collect the numeric values in fields order, then convert all at once.
+	numeric := make([]string, len(fields))
+	for i, field := range fields {
+		index, found := indices[field]
+		if field == "Date" || !found || index >= len(record) {
+			numeric[i] = "0"
+			continue
+		}
+		numeric[i] = strings.TrimSpace(record[index])
+	}
+	values, nulls, err := dl.StringRecordToFloat64Record(numeric, []int{0}, symbol)
+	if err != nil {
+		return dl.Data{}, false, err
+	}
+	if nulls > 0 {
+		return dl.Data{}, false, nil
+	}
+
+	data.Open, data.High, data.Low, data.Close, data.Volume = values[1], values[2], values[3], values[4], values[5]
+	data.AdjOpen, data.AdjHigh, data.AdjLow, data.AdjClose, data.AdjVolume = values[6], values[7], values[8], values[9], values[10]
+	if _, found := indices["AdjClose"]; !found {
+		data.AdjClose = data.Close
+	}
+	adj := 1.0
+	if data.Close != 0 {
+		adj = data.AdjClose / data.Close
+	}
+	if _, found := indices["AdjOpen"]; !found {
+		data.AdjOpen = mathh.Round(data.Open*adj, dl.InputPrecision)
+	}
+	if _, found := indices["AdjHigh"]; !found {
+		data.AdjHigh = mathh.Round(data.High*adj, dl.InputPrecision)
+	}
+	if _, found := indices["AdjLow"]; !found {
+		data.AdjLow = mathh.Round(data.Low*adj, dl.InputPrecision)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,84 @@
+package csvDirectory
+
+import (
+	"fmt"
+
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+const (
+	testDirectory = "./test"
+)
+
+func init() {
+	Init("test")
+	dl.Init("test")
+}
+
+func ExampleSource_NewGroup() {
+	// dia.csv uses the columns written by Group.SaveCSV.
+	group, err := Source{}.NewGroup(false, testDirectory, "testGroup", []string{"dia"})
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	dac := group.Issues[0].DatasetAsColumns
+	fmt.Printf("%s %s %+v %+v %+v\n", group.Issues[0].Symbol, dac.Date[0].Format(dl.DateFormat), dac.Open, dac.AdjClose, dac.Volume)
+
+	// qqq.csv is a broker export with different column names, delimiter, and date format,
+	// in Date descending order and without adjusted prices.
+	src := Source{Options: Options{
+		Directory: testDirectory,
+		Columns: map[string]string{"Date": "Trade Date", "Open": "Opening", "High": "Highest",
+			"Low": "Lowest", "Close": "Last", "Volume": "Shares"},
+		Comma:      ';',
+		DateFormat: "01/02/2006",
+	}}
+	group, err = src.NewGroup(false, "", "testGroup", []string{"qqq"})
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	dac = group.Issues[0].DatasetAsColumns
+	fmt.Printf("%s %s %+v %+v %+v\n", group.Issues[0].Symbol, dac.Date[0].Format(dl.DateFormat), dac.Open, dac.AdjOpen, dac.AdjClose)
+
+	_, err = Source{}.NewGroup(false, testDirectory, "testGroup", []string{"qqq"})
+	fmt.Printf("%+v\n", err)
+
+	// Output:
+	// dia 2022-01-03 [364.34 367.34] [343.99 346.05] [5.6241e+06 5.4622e+06]
+	// qqq 2022-01-03 [399.05 402.24] [399.05 402.24] [401.68 396.47]
+	// no Date column in header: [Trade Date;Opening;Highest;Lowest;Last;Shares]
+}
+
+func ExampleParseOptions() {
+	opts, err := ParseOptions("directory="+testDirectory+", date=Trade Date, open=Opening, high=Highest, low=Lowest, close=Last, "+
+		"volume=Shares, comma=;, dateFormat=01/02/2006", Defaults)
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	fmt.Printf("%q %s %d\n", opts.Comma, opts.DateFormat, len(opts.Columns))
+
+	// The registered Source uses Defaults.
+	defaults := Defaults
+	defer func() { Defaults = defaults }()
+	Defaults = opts
+	group, err := Source{}.NewGroup(false, "", "testGroup", []string{"qqq"})
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	fmt.Printf("%+v\n", group.Issues[0].DatasetAsColumns.Close)
+
+	for _, spec := range []string{"comma=;;", "colour=red"} {
+		_, err = ParseOptions(spec, Defaults)
+		fmt.Println(err)
+	}
+
+	// Output:
+	// ';' 01/02/2006 6
+	// [401.68 396.47]
+	// invalid option: comma=;;, error: comma must be one character
+	// invalid option: colour=red, error: unknown key
+}
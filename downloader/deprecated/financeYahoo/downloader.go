@@ -4,8 +4,10 @@
 package financeYahoo
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"math"
 	"strings"
 	"time"
@@ -15,6 +17,11 @@ import (
 	dl "github.com/paulfdunn/go-quantstudio/downloader"
 )
 
+const (
+	// SourceName is the name used to register this source with the downloader.
+	SourceName = "financeYahoo"
+)
+
 var (
 	appName string
 	// lp      func(level logh.LoghLevel, v ...interface{})
@@ -24,14 +31,41 @@ var (
 		"interval=1d&events=history&includeAdjustedClose=true"
 )
 
+// Source implements dl.Downloader and is registered with the downloader as SourceName.
+type Source struct{}
+
 func Init(appNameInit string) {
 	appName = appNameInit
 	// lp = logh.Map[appName].Println
 	lpf = logh.Map[appName].Printf
+
+	if err := dl.Register(SourceName, Source{}); err != nil {
+		lpf(logh.Warning, "registering source: %+v", err)
+	}
 }
 
+// NewGroup only supports daily data; dl.BarInterval must be dl.Interval1d.
 func NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
-	return dl.NewGroup(liveData, dataFilePath, name, symbols, yahooURL, urlCollectionDataToGroup)
+	return newGroupContext(context.Background(), liveData, dataFilePath, name, symbols)
+}
+
+// NewGroup implements dl.Downloader.
+func (Source) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	return NewGroup(liveData, dataFilePath, name, symbols)
+}
+
+// NewGroupContext implements dl.ContextDownloader.
+func (Source) NewGroupContext(ctx context.Context, liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	return newGroupContext(ctx, liveData, dataFilePath, name, symbols)
+}
+
+func newGroupContext(ctx context.Context, liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	if dl.BarInterval != dl.Interval1d {
+		err := fmt.Errorf("interval not supported by source %s: %s", SourceName, dl.BarInterval)
+		lpf(logh.Error, "%+v", err)
+		return nil, err
+	}
+	return dl.NewGroupContext(ctx, liveData, dataFilePath, name, symbols, yahooURL, urlCollectionDataToGroup)
 }
 
 // mapURLCollectionDataHeaderIndices makes a map of column names to struct members.
@@ -44,6 +44,15 @@ func ExampleNewGroup() {
 	//     {
 	//       "Symbol": "dia",
 	//       "URL": "https://query1.finance.yahoo.com/v7/finance/download/dia?period1=1640995200\u0026period2=1641340800\u0026interval=1d\u0026events=history\u0026includeAdjustedClose=true",
+	//       "Interval": "",
+	//       "Meta": {
+	//         "Currency": "",
+	//         "ExchangeName": "",
+	//         "InstrumentType": "",
+	//         "Timezone": "",
+	//         "RegularMarketPrice": 0,
+	//         "FirstTradeDate": "0001-01-01T00:00:00Z"
+	//       },
 	//       "Dataset": [
 	//         {
 	//           "Date": "2022-01-03T00:00:00Z",
@@ -87,11 +96,24 @@ func ExampleNewGroup() {
 	//         "AdjLow": [355.3271516900022,360.1427023133172],
 	//         "AdjClose": [358.6421,360.79],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     },
 	//     {
 	//       "Symbol": "qqq",
 	//       "URL": "https://query1.finance.yahoo.com/v7/finance/download/qqq?period1=1640995200\u0026period2=1641340800\u0026interval=1d\u0026events=history\u0026includeAdjustedClose=true",
+	//       "Interval": "",
+	//       "Meta": {
+	//         "Currency": "",
+	//         "ExchangeName": "",
+	//         "InstrumentType": "",
+	//         "Timezone": "",
+	//         "RegularMarketPrice": 0,
+	//         "FirstTradeDate": "0001-01-01T00:00:00Z"
+	//       },
 	//       "Dataset": [
 	//         {
 	//           "Date": "2022-01-03T00:00:00Z",
@@ -135,9 +157,15 @@ func ExampleNewGroup() {
 	//         "AdjLow": [393.9765157140012,390.4127642822912],
 	//         "AdjClose": [398.7414,393.5695],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     }
-	//   ]
+	//   ],
+	//   "Failures": null,
+	//   "FX": null
 	// }
 }
 
@@ -157,6 +185,15 @@ func Example_loadURLCollectionDataFromFile() {
 	//     {
 	//       "Symbol": "dia",
 	//       "URL": "https://query1.finance.yahoo.com/v7/finance/download/dia?period1=1640995200\u0026period2=1641340800\u0026interval=1d\u0026events=history\u0026includeAdjustedClose=true",
+	//       "Interval": "",
+	//       "Meta": {
+	//         "Currency": "",
+	//         "ExchangeName": "",
+	//         "InstrumentType": "",
+	//         "Timezone": "",
+	//         "RegularMarketPrice": 0,
+	//         "FirstTradeDate": "0001-01-01T00:00:00Z"
+	//       },
 	//       "Dataset": [
 	//         {
 	//           "Date": "2022-01-03T00:00:00Z",
@@ -200,11 +237,24 @@ func Example_loadURLCollectionDataFromFile() {
 	//         "AdjLow": [355.3271516900022,360.1427023133172],
 	//         "AdjClose": [358.6421,360.79],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     },
 	//     {
 	//       "Symbol": "qqq",
 	//       "URL": "https://query1.finance.yahoo.com/v7/finance/download/qqq?period1=1640995200\u0026period2=1641340800\u0026interval=1d\u0026events=history\u0026includeAdjustedClose=true",
+	//       "Interval": "",
+	//       "Meta": {
+	//         "Currency": "",
+	//         "ExchangeName": "",
+	//         "InstrumentType": "",
+	//         "Timezone": "",
+	//         "RegularMarketPrice": 0,
+	//         "FirstTradeDate": "0001-01-01T00:00:00Z"
+	//       },
 	//       "Dataset": [
 	//         {
 	//           "Date": "2022-01-03T00:00:00Z",
@@ -248,8 +298,14 @@ func Example_loadURLCollectionDataFromFile() {
 	//         "AdjLow": [393.9765157140012,390.4127642822912],
 	//         "AdjClose": [398.7414,393.5695],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     }
-	//   ]
+	//   ],
+	//   "Failures": null,
+	//   "FX": null
 	// }
 }
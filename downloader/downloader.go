@@ -2,13 +2,15 @@ package downloader
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/paulfdunn/go-helper/encodingh/v2/jsonh"
@@ -17,6 +19,8 @@ import (
 	"github.com/paulfdunn/go-helper/neth/v2/httph"
 )
 
+// Downloader is implemented by each source of price data. Implementations make themselves
+// available by name using Register, and callers look them up using Source.
 type Downloader interface {
 	NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*Group, error)
 }
@@ -25,17 +29,39 @@ type Downloader interface {
 type Group struct {
 	Name   string
 	Issues []Issue
+	// Failures are the symbols for which no data was loaded.
+	Failures []Failure
+	// FX are the FX pairs used to convert Issues to BaseCurrency; see ConvertCurrency.
+	FX []Issue
+	// validated is true once validateGroup has validated the Group.
+	validated bool
 }
 
 // Issue is an item for which Data is collected. I.E. a stock, ETF, etc.
 type Issue struct {
 	Symbol string
 	URL    string
+	// Interval of the bars in Dataset and DatasetAsColumns.
+	Interval Interval
+	// Meta is descriptive data for the Issue, for sources that provide it.
+	Meta Meta
 	// Dataset is row based data for data sources in that format; convert to DatasetAsColumns
 	// using ToDatasetAsColumns().
 	Dataset []Data
 	// DatasetAsColumns is column based data as that is sometimes easier to work with.
 	DatasetAsColumns DatasetAsColumns
+	// Dividends and Splits are the corporate actions for the Issue, in Date ascending order,
+	// for sources that provide them. Close and Dividend.Amount are split adjusted.
+	Dividends []Dividend
+	Splits    []Split
+	// BaseCurrency and FXRate are set by Group.ConvertCurrency; FXRate[i] is the value of one
+	// unit of Meta.Currency in BaseCurrency on DatasetAsColumns.Date[i].
+	BaseCurrency string
+	FXRate       []float64
+	// Profile is the fundamental data for the Issue, when loaded; see NewProfiles.
+	Profile *Profile `json:",omitempty"`
+	// Cash is the value of cash on each DatasetAsColumns.Date, when set; see Group.SetCash.
+	Cash []float64 `json:",omitempty"`
 }
 
 // Data is used to Unmarshal data. This structure must
@@ -55,6 +81,30 @@ type Data struct {
 	AdjVolume float64
 }
 
+// Meta is descriptive data for an Issue.
+type Meta struct {
+	Currency       string
+	ExchangeName   string
+	InstrumentType string
+	// Timezone is the IANA name of the exchange timezone; I.E. America/New_York
+	Timezone           string
+	RegularMarketPrice float64
+	FirstTradeDate     time.Time
+}
+
+// Dividend is a cash dividend per share, paid to holders on the ex-dividend Date.
+type Dividend struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Split is a stock split on Date; each share becomes Numerator/Denominator shares.
+type Split struct {
+	Date        time.Time
+	Numerator   float64
+	Denominator float64
+}
+
 type DatasetAsColumns struct {
 	Date      []time.Time
 	Open      []float64
@@ -74,6 +124,7 @@ type URLCollectionDataToGroup func(urlData []httph.URLCollectionData, urlSymbolM
 const (
 	BinaryExtension = ".bin"
 	CSVExtension    = ".csv"
+	GroupExtension  = ".json"
 	DateFormat      = "2006-01-02"
 
 	// Floats are rounded to this number of decimal points. Yahoo will slightly alter some values
@@ -95,6 +146,14 @@ var (
 	LatestDate   = time.Now().AddDate(0, 0, 1).Unix()
 
 	URLCollectionTimeout = time.Duration(10 * time.Second)
+
+	// Incremental, when true, makes live downloads only request bars after the last bar in the
+	// prior data, rather than all data starting at EarliestDate.
+	Incremental = false
+
+	// sources is the registry of Downloader, keyed by name.
+	sources      = make(map[string]Downloader)
+	sourcesMutex sync.RWMutex
 )
 
 func Init(appNameInit string) {
@@ -105,39 +164,207 @@ func Init(appNameInit string) {
 
 // NewGroup is a factory for Group.
 // liveData == true, data is downloaded from Yahoo; otherwise it is loaded from a file saved
-// from the prior call.
+// from the prior call. When Incremental == true, live downloads only request bars after the
+// last bar in the prior data; see newGroupIncremental.
+// When BaseCurrency is set, the Issues are converted to BaseCurrency; see Group.ConvertCurrency.
+// It is an error to use MissingMark without a Validation that repairs or drops the marked bars.
+// url is requested with bars of BarInterval, and files are named using IntervalKey(dataFilePath, BarInterval).
 func NewGroup(liveData bool, dataFilePath string, name string, symbols []string, url string,
 	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
-	// updateLatestDate()
-	urls, urlSymbolMap := GenerateURLs(symbols, url)
+	return NewGroupContext(context.Background(), liveData, dataFilePath, name, symbols, url, callbackURLCollectionDataToGroup)
+}
 
-	var urlData []httph.URLCollectionData
-	if liveData {
-		// Get data for all symbols and save it.
-		urlData = collectGroup(urls)
-		err := saveURLCollectionData(urlData, dataFilePath)
+// NewGroupContext is NewGroup, with downloads that report progress to the ProgressFunc of ctx
+// (see WithProgress) and stop when ctx is done. Requests already made are not interrupted, so
+// cancellation takes up to URLCollectionTimeout. A canceled download returns ctx.Err() and
+// saves nothing.
+func NewGroupContext(ctx context.Context, liveData bool, dataFilePath string, name string, symbols []string, url string,
+	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
+	if err := CheckMissingBars(MissingBars, Validation); err != nil {
+		return nil, err
+	}
+	// updateLatestDate()
+	dataFilePath = IntervalKey(dataFilePath, BarInterval)
+	var group *Group
+	var err error
+	switch {
+	case liveData && Incremental:
+		group, err = newGroupIncremental(ctx, dataFilePath, name, symbols, url, callbackURLCollectionDataToGroup)
+		if err != nil && ctx.Err() == nil {
+			lpf(logh.Warning, "incremental download failed, downloading all data, error: %+v", err)
+			group, err = newGroupLive(ctx, dataFilePath, name, symbols, url, callbackURLCollectionDataToGroup)
+		}
+	case liveData:
+		group, err = newGroupLive(ctx, dataFilePath, name, symbols, url, callbackURLCollectionDataToGroup)
+	default:
+		group, err = loadGroup(dataFilePath, name, symbols, url, callbackURLCollectionDataToGroup)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		var err error
-		if urlData, err = LoadURLCollectionDataFromFile(dataFilePath); err != nil {
+		if err := validateGroup(group); err != nil {
 			return nil, err
 		}
+		if BaseCurrency != "" {
+			convertCurrency(group)
+		}
+		return group, nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGroup(group); err != nil {
+		return nil, err
+	}
+	if BaseCurrency != "" {
+		convertGroup(ctx, group, url, callbackURLCollectionDataToGroup)
+	}
+	if err := ctx.Err(); err != nil {
+		lpf(logh.Warning, "download canceled, group: %s", name)
+		return nil, err
 	}
 
-	group, err := callbackURLCollectionDataToGroup(urlData, urlSymbolMap, name)
+	if err := group.SaveCSV(dataFilePath); err != nil {
+		lpf(logh.Error, "saving group as csv: %+v", err)
+		return nil, err
+	}
+	if err := group.SaveJSON(dataFilePath); err != nil {
+		lpf(logh.Error, "saving group as json: %+v", err)
+		return nil, err
+	}
+	if SnapshotStore != nil {
+		// The data was downloaded successfully, so errors are only logged.
+		if _, err := SnapshotStore.Save(group); err == nil {
+			removed, _ := SnapshotStore.Prune(IntervalKey(group.Name, group.Interval()))
+			for _, snapshot := range removed {
+				lpf(logh.Info, "Snapshot removed; group: %s, file: %s", snapshot.Group, snapshot.FilePath)
+			}
+		}
+	}
+	return group, nil
+}
+
+// LoadGroupFromFile loads a Group saved by Group.SaveJSON.
+func LoadGroupFromFile(dataFilePath string) (*Group, error) {
+	bIn, err := os.ReadFile(dataFilePath + GroupExtension)
+	if err != nil {
+		lpf(logh.Error, "reading group failed, error:%s", err)
+		return nil, err
+	}
+	group := new(Group)
+	err = json.Unmarshal(bIn, group)
 	if err != nil {
+		lpf(logh.Error, "unmarshaling group failed, error:%s", err)
 		return nil, err
 	}
-	if liveData {
-		err := group.SaveCSV(dataFilePath)
+	return group, nil
+}
+
+// Register makes a Downloader available by name. It is an error to register a nil Downloader
+// or to register the same name twice.
+func Register(name string, d Downloader) error {
+	if d == nil {
+		return fmt.Errorf("cannot register nil Downloader for source: %s", name)
+	}
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	if _, ok := sources[name]; ok {
+		return fmt.Errorf("source already registered: %s", name)
+	}
+	sources[name] = d
+	return nil
+}
+
+// Source returns the Downloader registered with name.
+func Source(name string) (Downloader, error) {
+	sourcesMutex.RLock()
+	defer sourcesMutex.RUnlock()
+	d, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source: %s, registered sources: %v", name, sourceNames())
+	}
+	return d, nil
+}
+
+// Sources returns the names of all registered Downloader, sorted.
+func Sources() []string {
+	sourcesMutex.RLock()
+	defer sourcesMutex.RUnlock()
+	return sourceNames()
+}
+
+// newGroupLive downloads data for all symbols starting at EarliestDate, saves the raw data,
+// and returns the data as a Group. Symbols that fail are recorded in Group.Failures.
+func newGroupLive(ctx context.Context, dataFilePath string, name string, symbols []string, url string,
+	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
+	urls, urlSymbolMap := GenerateURLs(symbols, url)
+	urlData := collectGroup(ctx, urls, urlSymbolMap)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Only save the successful requests, so the saved data can always be processed.
+	saved := make([]httph.URLCollectionData, 0, len(urlData))
+	for _, ucd := range urlData {
+		if ucd.Err == nil {
+			saved = append(saved, ucd)
+		}
+	}
+	err := saveURLCollectionData(saved, dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return urlCollectionDataToGroupPartial(urlData, urlSymbolMap, name, callbackURLCollectionDataToGroup)
+}
+
+// loadGroup loads data saved by a prior call to NewGroup. The Group saved by Group.SaveJSON is
+// used when it exists; otherwise the raw data saved by saveURLCollectionData is processed.
+func loadGroup(dataFilePath string, name string, symbols []string, url string,
+	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
+	if _, err := os.Stat(dataFilePath + GroupExtension); err == nil {
+		lpf(logh.Warning, "Prior data loaded from file.")
+		group, err := LoadGroupFromFile(dataFilePath)
 		if err != nil {
-			lpf(logh.Error, "saving group as csv: %+v", err)
 			return nil, err
 		}
+		group.Name = name
+		group.Issues = issuesOf(group.Issues, symbols)
+		return group, nil
 	}
-	return group, nil
+
+	_, urlSymbolMap := GenerateURLs(symbols, url)
+	urlData, err := LoadURLCollectionDataFromFile(dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return urlCollectionDataToGroupPartial(urlData, urlSymbolMap, name, callbackURLCollectionDataToGroup)
+}
+
+// issuesOf returns the Issues of issues with a symbol in symbols. Symbols without an Issue are
+// logged; the prior data was saved for other symbols.
+func issuesOf(issues []Issue, symbols []string) []Issue {
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[symbol] = true
+	}
+	out := make([]Issue, 0, len(symbols))
+	for _, iss := range issues {
+		if wanted[iss.Symbol] {
+			out = append(out, iss)
+			delete(wanted, iss.Symbol)
+		}
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for _, symbol := range symbols {
+			if wanted[symbol] {
+				missing = append(missing, symbol)
+			}
+		}
+		lpf(logh.Warning, "symbols not in the prior data, download live data to add them: %v", missing)
+	}
+	return out
 }
 
 func BaseURL(url string) string {
@@ -147,12 +374,22 @@ func BaseURL(url string) string {
 // GenerateURLs generates URLs for the symbols as well as a symbol to URL map for
 // use with data returned from collectGroup
 func GenerateURLs(symbols []string, url string) (urls []string, urlSymbolMap map[string]string) {
+	return generateURLsFrom(symbols, url, EarliestDate)
+}
+
+// generateURLsFrom is GenerateURLs with data requested starting at start (Unix time) rather than
+// EarliestDate.
+// start is limited to the earliest data available for BarInterval.
+func generateURLsFrom(symbols []string, url string, start int64) (urls []string, urlSymbolMap map[string]string) {
+	if earliest := BarInterval.Earliest(time.Now()); start < earliest {
+		start = earliest
+	}
 	// Make the URLs from which to fetch data.
 	urls = make([]string, 0, len(symbols))
 	// Keep a map of base URL to symbol.
 	urlSymbolMap = make(map[string]string)
 	for _, s := range symbols {
-		url := fmt.Sprintf(url, s, EarliestDate, LatestDate)
+		url := fmt.Sprintf(url, s, start, LatestDate)
 		urls = append(urls, url)
 		urlSymbolMap[BaseURL(url)] = s
 		lpf(logh.Debug, "Symbol: %s, URL: %s", s, url)
@@ -212,6 +449,14 @@ func URLCollectionDataDateOrder(records [][]string, urlCollectionDataHeaderIndic
 	return orderAsc, dateFirst, dateLast
 }
 
+// Interval returns the Interval of the Issues in grp.
+func (grp Group) Interval() Interval {
+	if len(grp.Issues) == 0 {
+		return BarInterval
+	}
+	return grp.Issues[0].Interval.normalize()
+}
+
 func (grp Group) SaveCSV(dataFilePath string) error {
 	f, err := os.Create(dataFilePath + CSVExtension)
 	if err != nil {
@@ -230,7 +475,7 @@ func (grp Group) SaveCSV(dataFilePath string) error {
 		}
 		for indx := range issue.DatasetAsColumns.Date {
 			_, err := w.WriteString(fmt.Sprintf("%s, %s, %10.4f, %10.4f, %10.4f, %10.4f, %15.4f, %10.4f, %10.4f, %10.4f, %10.4f, %15.4f\n",
-				issue.Symbol, issue.DatasetAsColumns.Date[indx].Format(DateFormat),
+				issue.Symbol, issue.DatasetAsColumns.Date[indx].Format(issue.Interval.DateFormat()),
 				issue.DatasetAsColumns.Open[indx], issue.DatasetAsColumns.High[indx], issue.DatasetAsColumns.Low[indx], issue.DatasetAsColumns.Close[indx], issue.DatasetAsColumns.Volume[indx],
 				issue.DatasetAsColumns.AdjOpen[indx], issue.DatasetAsColumns.AdjHigh[indx], issue.DatasetAsColumns.AdjLow[indx], issue.DatasetAsColumns.AdjClose[indx], issue.DatasetAsColumns.AdjVolume[indx]))
 			if err != nil {
@@ -243,6 +488,48 @@ func (grp Group) SaveCSV(dataFilePath string) error {
 	return nil
 }
 
+// SaveJSON saves the Group so it can be loaded with LoadGroupFromFile.
+func (grp Group) SaveJSON(dataFilePath string) error {
+	bOut, err := json.Marshal(grp)
+	if err != nil {
+		lpf(logh.Error, "marshalling group failed, error:%s", err)
+		return err
+	}
+	err = os.WriteFile(dataFilePath+GroupExtension, bOut, 0644)
+	if err != nil {
+		lpf(logh.Error, "writing group failed, error:%s", err)
+		return err
+	}
+	return nil
+}
+
+// Append returns a DatasetAsColumns with the rows of other appended to the rows of dac.
+func (dac DatasetAsColumns) Append(other DatasetAsColumns) DatasetAsColumns {
+	return DatasetAsColumns{
+		Date:      append(slices.Clip(dac.Date), other.Date...),
+		Open:      append(slices.Clip(dac.Open), other.Open...),
+		High:      append(slices.Clip(dac.High), other.High...),
+		Low:       append(slices.Clip(dac.Low), other.Low...),
+		Close:     append(slices.Clip(dac.Close), other.Close...),
+		Volume:    append(slices.Clip(dac.Volume), other.Volume...),
+		AdjOpen:   append(slices.Clip(dac.AdjOpen), other.AdjOpen...),
+		AdjHigh:   append(slices.Clip(dac.AdjHigh), other.AdjHigh...),
+		AdjLow:    append(slices.Clip(dac.AdjLow), other.AdjLow...),
+		AdjClose:  append(slices.Clip(dac.AdjClose), other.AdjClose...),
+		AdjVolume: append(slices.Clip(dac.AdjVolume), other.AdjVolume...),
+	}
+}
+
+// Slice returns the rows of dac in the range [start, end).
+func (dac DatasetAsColumns) Slice(start int, end int) DatasetAsColumns {
+	return DatasetAsColumns{
+		Date: dac.Date[start:end], Open: dac.Open[start:end], High: dac.High[start:end],
+		Low: dac.Low[start:end], Close: dac.Close[start:end], Volume: dac.Volume[start:end],
+		AdjOpen: dac.AdjOpen[start:end], AdjHigh: dac.AdjHigh[start:end], AdjLow: dac.AdjLow[start:end],
+		AdjClose: dac.AdjClose[start:end], AdjVolume: dac.AdjVolume[start:end],
+	}
+}
+
 func (dt Data) String() string {
 	out, err := json.MarshalIndent(dt, "", "  ")
 	lpf(logh.Error, "calling json.MarshalIndent: %s", err)
@@ -261,6 +548,21 @@ func (grp Group) String() string {
 	return string(jsonh.PrettyJSON(out))
 }
 
+// Description returns the Symbol followed by a summary of Meta, when available;
+// I.E. "dia (ETF, USD, NYSEArca)"
+func (iss Issue) Description() string {
+	var parts []string
+	for _, v := range []string{iss.Meta.InstrumentType, iss.Meta.Currency, iss.Meta.ExchangeName} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return iss.Symbol
+	}
+	return fmt.Sprintf("%s (%s)", iss.Symbol, strings.Join(parts, ", "))
+}
+
 func (iss Issue) String() string {
 	out, err := json.MarshalIndent(iss, "", "  ")
 	lpf(logh.Error, "calling json.MarshalIndent: %s", err)
@@ -301,18 +603,27 @@ func (iss Issue) ToDatasetAsColumns() DatasetAsColumns {
 	return out
 }
 
-func collectGroup(urls []string) []httph.URLCollectionData {
-	// Get data for all symbols.
-	headers := []httph.Header{
-		{Key: "User-Agent", Value: "Golang_Spider_Bot/3.0"},
+// validateGroup validates group using Validation; nothing is done when Validation is nil or
+// group was already validated.
+func validateGroup(group *Group) error {
+	if Validation == nil || group.validated {
+		return nil
+	}
+	if _, err := group.Validate(*Validation); err != nil {
+		return err
 	}
-	urlData := httph.CollectURLs(urls, URLCollectionTimeout, http.MethodGet, Threads, headers)
-	// Response cannot be Marshalled; set to nil to prevent errors when saving the data
-	// and also generating SA1026 lint error.
-	for i := range urlData {
-		urlData[i].Response = nil
+	group.validated = true
+	return nil
+}
+
+// sourceNames returns the sorted names of the registered sources; the caller must hold sourcesMutex.
+func sourceNames() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
 	}
-	return urlData
+	sort.Strings(names)
+	return names
 }
 
 func saveURLCollectionData(urlData []httph.URLCollectionData, dataFilePath string) error {
@@ -1,8 +1,16 @@
 package downloader
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
 	"time"
+
+	"github.com/paulfdunn/go-helper/neth/v2/httph"
 )
 
 var (
@@ -12,6 +20,7 @@ var (
 )
 
 func init() {
+	Init("test")
 	// For testing, override latestDate so it is a fixed value. Otherwise
 	// it changes every day and the tests fail.
 	LatestDate = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
@@ -39,3 +48,422 @@ func Example_generateURLs() {
 	// https://query1.finance.yahoo.com/v7/finance/download/qqq?period1=631152000&period2=1672531200&interval=1d&events=history&includeAdjustedClose=true
 	// urlSymbolMap: map[https://query1.finance.yahoo.com/v7/finance/download/dia:dia https://query1.finance.yahoo.com/v7/finance/download/qqq:qqq]
 }
+
+func ExampleInterval() {
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"5m", "1d", "1wk", "2d"} {
+		interval, err := ParseInterval(name)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			continue
+		}
+		earliest := time.Unix(interval.Earliest(now), 0).UTC()
+		fmt.Printf("%s %t %s %s %s\n", interval, interval.Intraday(), IntervalKey("ETFs", interval),
+			earliest.Format(interval.DateFormat()), now.Format(interval.DateFormat()))
+	}
+
+	// Output:
+	// 5m true ETFs-5m 2024-01-01 01:00 2024-03-01 00:00
+	// 1d false ETFs 1990-01-01 2024-03-01
+	// 1wk false ETFs-1wk 1990-01-01 2024-03-01
+	// invalid interval: 2d, valid intervals: [1m 5m 15m 1h 1d 1wk 1mo]
+}
+
+func ExampleCache() {
+	cache := NewCache()
+	_, ok := cache.Get("ETFs")
+	fmt.Println(ok)
+
+	cache.Set("ETFs", &Group{Name: "ETFs", Issues: []Issue{{Symbol: "dia"}}})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if entry, ok := cache.Get("ETFs"); !ok || entry.Group == nil {
+				fmt.Println("missing group")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			cache.Set("ETFs", &Group{Name: "ETFs"})
+		}()
+	}
+	wg.Wait()
+
+	// A failed download (nil Group) does not replace the data.
+	entry := cache.Set("ETFs", nil)
+	fmt.Println(entry.Version, entry.Group.Name)
+	entry = cache.Set("bonds", &Group{Name: "bonds"})
+	fmt.Println(entry.Version, cache.Names())
+
+	// Output:
+	// false
+	// 11 ETFs
+	// 12 [ETFs bonds]
+}
+
+func Example_collectGroup() {
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		count := requests[r.URL.Path]
+		mutex.Unlock()
+		switch {
+		case r.URL.Path == "/limited" && count == 1:
+			// Retry-After is limited to MaxBackoff.
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintf(w, "%s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	retry, rateLimit := Retry, RateLimit
+	defer func() { Retry, RateLimit = retry, rateLimit }()
+	Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, Jitter: 0.2}
+	RateLimit = NewRateLimiter(1000, 1)
+
+	urls, urlSymbolMap := GenerateURLs([]string{"ok", "limited", "missing", "down"}, server.URL+"/%s?period1=%d&period2=%d")
+	urlData := collectGroup(context.Background(), urls, urlSymbolMap)
+	for _, ucd := range urlData {
+		fmt.Printf("%s %q %t\n", urlSymbolMap[BaseURL(ucd.URL)], ucd.Bytes, ucd.Err != nil)
+	}
+	fmt.Printf("requests: ok:%d limited:%d missing:%d down:%d\n", requests["/ok"], requests["/limited"], requests["/missing"], requests["/down"])
+
+	toGroup := func(urlData []httph.URLCollectionData, urlSymbolMap map[string]string, name string) (*Group, error) {
+		return &Group{Name: name, Issues: []Issue{{Symbol: urlSymbolMap[BaseURL(urlData[0].URL)]}}}, nil
+	}
+	group, err := urlCollectionDataToGroupPartial(urlData, urlSymbolMap, "test", toGroup)
+	fmt.Printf("%d %d %s %s %v\n", len(group.Issues), len(group.Failures), group.Failures[0].Symbol, group.Failures[1].Symbol, err)
+
+	// Output:
+	// ok "/ok" false
+	// limited "/limited" false
+	// missing "" true
+	// down "" true
+	// requests: ok:1 limited:2 missing:1 down:3
+	// 2 2 missing down <nil>
+}
+
+func ExampleRateLimiter() {
+	rl := NewRateLimiter(2, 2)
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	// The bucket starts full, then refills at 2 tokens per second.
+	fmt.Println(rl.take(now), rl.take(now), rl.take(now))
+	fmt.Println(rl.take(now.Add(250*time.Millisecond)), rl.take(now.Add(500*time.Millisecond)))
+
+	// Output:
+	// 0s 0s 500ms
+	// 250ms 0s
+}
+
+type testSource struct{}
+
+func (testSource) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*Group, error) {
+	return &Group{Name: name}, nil
+}
+
+func ExampleRegister() {
+	if err := Register("testSource", testSource{}); err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	fmt.Printf("%+v\n", Register("testSource", testSource{}))
+
+	d, err := Source("testSource")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+	}
+	group, _ := d.NewGroup(false, "", "testGroup", nil)
+	fmt.Printf("%s\n", group.Name)
+	fmt.Printf("%+v\n", Sources())
+
+	_, err = Source("noSuchSource")
+	fmt.Printf("%+v\n", err)
+
+	// Output:
+	// source already registered: testSource
+	// testGroup
+	// [testSource]
+	// unknown source: noSuchSource, registered sources: [testSource]
+}
+
+func testIssue(days []int, close []float64, adjClose []float64) Issue {
+	dac := DatasetAsColumns{}
+	for i, d := range days {
+		dac.Date = append(dac.Date, time.Date(2022, time.January, d, 14, 30, 0, 0, time.UTC))
+		dac.Open = append(dac.Open, close[i])
+		dac.High = append(dac.High, close[i])
+		dac.Low = append(dac.Low, close[i])
+		dac.Close = append(dac.Close, close[i])
+		dac.Volume = append(dac.Volume, 100)
+		dac.AdjOpen = append(dac.AdjOpen, adjClose[i])
+		dac.AdjHigh = append(dac.AdjHigh, adjClose[i])
+		dac.AdjLow = append(dac.AdjLow, adjClose[i])
+		dac.AdjClose = append(dac.AdjClose, adjClose[i])
+		dac.AdjVolume = append(dac.AdjVolume, 0)
+	}
+	return Issue{Symbol: "test", DatasetAsColumns: dac}
+}
+
+func Example_issuesOf() {
+	issues := []Issue{{Symbol: "dia"}, {Symbol: "qqq"}, {Symbol: "spy"}}
+	for _, iss := range issuesOf(issues, []string{"spy", "dia", "iwm"}) {
+		fmt.Println(iss.Symbol)
+	}
+
+	// Output:
+	// dia
+	// spy
+}
+
+func ExampleGroup_ConvertCurrency() {
+	london := testIssue([]int{3, 4, 5, 6}, []float64{1000, 1010, 1020, 1030}, []float64{1000, 1010, 1020, 1030})
+	london.Symbol, london.Meta.Currency = "isf.l", "GBp"
+	paris := testIssue([]int{3, 4}, []float64{50, 51}, []float64{50, 51})
+	paris.Symbol, paris.Meta.Currency = "cac.pa", "EUR"
+	us := testIssue([]int{3, 4}, []float64{300, 301}, []float64{300, 301})
+	us.Symbol, us.Meta.Currency = "dia", "USD"
+	// No FX bar on the 5th, so the rate from the 4th is used.
+	gbpusd := testIssue([]int{3, 4, 6}, []float64{1.20, 1.21, 1.23}, []float64{1.20, 1.21, 1.23})
+	gbpusd.Symbol = FXSymbol("GBP", "USD")
+
+	grp := Group{Name: "test", Issues: []Issue{london, paris, us}, FX: []Issue{gbpusd}}
+	fmt.Println(grp.FXSymbols("USD"))
+	err := grp.ConvertCurrency("USD")
+	fmt.Println(err)
+	for _, iss := range grp.Issues {
+		fmt.Printf("%s %q %v\n", iss.Symbol, iss.BaseCurrency, iss.FXRate)
+	}
+
+	// Output:
+	// [GBPUSD=X EURUSD=X]
+	// no FX data for currencies: [EUR], base currency: USD
+	// isf.l "USD" [0.012 0.0121 0.0121 0.0123]
+	// cac.pa "" []
+	// dia "USD" [1 1]
+}
+
+func ExampleGroup_SetCash() {
+	dia := testIssue([]int{3, 4, 5, 6}, []float64{300, 301, 302, 303}, []float64{300, 301, 302, 303})
+	dia.Symbol = "dia"
+	// No cash bar on the 5th, so the value from the 4th is used.
+	sgov := testIssue([]int{3, 4, 6}, []float64{100, 100.01, 100.03}, []float64{100, 100.01, 100.03})
+	sgov.Symbol = "sgov"
+	irx := testIssue([]int{3, 4, 6}, []float64{3.65, 7.3, 7.3}, []float64{3.65, 7.3, 7.3})
+	irx.Symbol = "^irx"
+
+	grp := Group{Name: "test", Issues: []Issue{dia, sgov, irx}}
+	fmt.Println(grp.SetCash("bil", false))
+	fmt.Println(grp.SetCash("SGOV", false), grp.Issues[0].Cash)
+	fmt.Println(grp.SetCash("^irx", true))
+	for _, cash := range grp.Issues[0].Cash {
+		fmt.Printf("%.6f ", cash)
+	}
+	fmt.Println()
+
+	// Output:
+	// no cash data, symbol: bil, group: test
+	// <nil> [100 100.01 100.01 100.03]
+	// <nil>
+	// 1.000000 1.000098 1.000098 1.000484
+}
+
+func Example_mergeIssue() {
+	prior := testIssue([]int{3, 4, 5}, []float64{10, 11, 12}, []float64{9, 10, 11})
+
+	// The first bar of the update overlaps the last prior bar and is unchanged.
+	update := testIssue([]int{5, 6, 7}, []float64{12, 13, 14}, []float64{11, 12, 13})
+	merged, ok := mergeIssue(prior, update)
+	fmt.Printf("%t %d %+v %+v\n", ok, len(merged.DatasetAsColumns.Date), merged.DatasetAsColumns.Close, merged.DatasetAsColumns.AdjClose)
+	// prior must not be modified.
+	fmt.Printf("%+v\n", prior.DatasetAsColumns.Close)
+
+	// A dividend changed the adjusted price of the overlapping bar.
+	update = testIssue([]int{5, 6, 7}, []float64{12, 13, 14}, []float64{10.9, 12, 13})
+	_, ok = mergeIssue(prior, update)
+	fmt.Printf("%t\n", ok)
+
+	// The update does not include the last prior bar.
+	update = testIssue([]int{6, 7}, []float64{13, 14}, []float64{12, 13})
+	_, ok = mergeIssue(prior, update)
+	fmt.Printf("%t\n", ok)
+
+	// Output:
+	// true 5 [10 11 12 13 14] [9 10 11 12 13]
+	// [10 11 12]
+	// false
+	// false
+}
+
+func ExampleStore() {
+	directory, err := os.MkdirTemp("", "store")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(directory)
+
+	st, _ := NewStore(directory, Retention{KeepLast: 3, MaxAge: 30 * 24 * time.Hour})
+	for _, day := range []int{1, 10, 20, 25} {
+		group := &Group{Name: "testGroup", Issues: []Issue{{Symbol: fmt.Sprintf("day%d", day)}}}
+		if _, err := st.saveAt(group, time.Date(2022, time.March, day, 23, 0, 0, 0, time.UTC)); err != nil {
+			fmt.Printf("%+v\n", err)
+		}
+	}
+	snapshots, _ := st.List("testGroup")
+	fmt.Printf("snapshots: %d\n", len(snapshots))
+
+	group, snapshot, _ := st.LoadAsOf("testGroup", time.Date(2022, time.March, 15, 0, 0, 0, 0, time.UTC))
+	fmt.Printf("%s %s\n", snapshot.Time.Format(SnapshotTimeFormat), group.Issues[0].Symbol)
+	_, _, err = st.LoadAsOf("testGroup", time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC))
+	fmt.Printf("%+v\n", err)
+
+	// KeepLast removes the snapshot from the 1st, MaxAge the snapshot from the 10th.
+	removed, _ := st.pruneAt("testGroup", time.Date(2022, time.April, 15, 0, 0, 0, 0, time.UTC))
+	for _, s := range removed {
+		fmt.Printf("removed: %s\n", s.Time.Format(SnapshotTimeFormat))
+	}
+	snapshots, _ = st.List("testGroup")
+	for _, s := range snapshots {
+		fmt.Printf("kept: %s\n", s.Time.Format(SnapshotTimeFormat))
+	}
+
+	// Output:
+	// snapshots: 4
+	// 20220310T230000.000000000Z day10
+	// no snapshot for group: testGroup at or before: 2022-02-01T00:00:00Z
+	// removed: 20220301T230000.000000000Z
+	// removed: 20220310T230000.000000000Z
+	// kept: 20220320T230000.000000000Z
+	// kept: 20220325T230000.000000000Z
+}
+
+func ExampleStore_partial() {
+	directory, err := os.MkdirTemp("", "store")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(directory)
+
+	st, _ := NewStore(directory, Retention{})
+	// Snapshots saved in the same second are both kept.
+	t := time.Date(2022, time.March, 1, 23, 0, 0, 0, time.UTC)
+	group := &Group{Name: "testGroup", Issues: []Issue{{Symbol: "dia"}}}
+	st.saveAt(group, t)
+	st.saveAt(group, t.Add(time.Millisecond))
+	// A snapshot that cannot be written is removed.
+	group.Issues[0].DatasetAsColumns.Close = []float64{math.NaN()}
+	_, err = st.saveAt(group, t.Add(time.Second))
+	fmt.Printf("%+v\n", err)
+	snapshots, _ := st.List("testGroup")
+	for _, s := range snapshots {
+		fmt.Printf("%s\n", s.Time.Format(SnapshotTimeFormat))
+	}
+
+	// Output:
+	// json: unsupported value: NaN
+	// 20220301T230000.000000000Z
+	// 20220301T230000.001000000Z
+}
+
+func ExampleIssue_Validate() {
+	days := []int{3, 4, 5, 6, 7, 10, 11, 12, 13, 14, 19, 20}
+	close := []float64{10, 10.1, 10.2, 10.1, 10.2, 10.3, 30, 10.4, 10.3, 10.4, 10.5, 10.6}
+	iss := testIssue(days, close, close)
+	dac := &iss.DatasetAsColumns
+	// high < low
+	dac.High[1], dac.Low[1] = 10.0, 10.2
+	// close outside [low, high]
+	dac.High[2], dac.Low[2] = 10.1, 10.0
+	// NaN
+	dac.Open[8] = math.NaN()
+	// Duplicate; the 7th is then missing.
+	dac.Date[4] = dac.Date[3]
+	// Zero volume
+	dac.Volume[5] = 0
+	// The bar on the 11th is a spike, the 18th is missing; the 17th is a holiday.
+
+	report := iss.Validate(ValidationRules{Policy: PolicyRepair, SpikeSigma: 2})
+	for _, f := range report.Findings {
+		fmt.Printf("%s %s %s\n", f.Date.Format(DateFormat), f.Check, f.Action)
+	}
+	fmt.Printf("%s\n", report)
+	fmt.Printf("%+v\n", iss.DatasetAsColumns.Close)
+	fmt.Printf("%+v %+v %+v %+v\n", iss.DatasetAsColumns.High[1], iss.DatasetAsColumns.Low[1], iss.DatasetAsColumns.High[2], iss.DatasetAsColumns.Open[7])
+
+	// Output:
+	// 2022-01-04 high < low repaired
+	// 2022-01-05 close outside [low, high] repaired
+	// 2022-01-06 duplicate timestamp repaired
+	// 2022-01-10 zero volume reported
+	// 2022-01-13 NaN or null value repaired
+	// 2022-01-11 price spike repaired
+	// 2022-01-10 missing trading days reported
+	// 2022-01-19 missing trading days reported
+	// symbol: test, bars:12, findings:8, NaN or null value:1, close outside [low, high]:1, duplicate timestamp:1, high < low:1, missing trading days:2, price spike:1, zero volume:1
+	// [10 10.1 10.2 10.2 10.3 10.3 10.4 10.3 10.4 10.5 10.6]
+	// 10.2 10 10.2 10.4
+}
+
+func ExampleIssue_Validate_nonPositive() {
+	days := []int{3, 4, 5, 6, 7}
+	close := []float64{-1, 10, 10.1, 0, 10.2}
+	iss := testIssue(days, close, close)
+
+	// The first bar has no prior close, so it is dropped rather than repaired.
+	report := iss.Validate(ValidationRules{Policy: PolicyRepair})
+	for _, f := range report.Findings {
+		fmt.Printf("%s %s %s\n", f.Date.Format(DateFormat), f.Check, f.Action)
+	}
+	fmt.Printf("%+v\n", iss.DatasetAsColumns.Close)
+
+	// Output:
+	// 2022-01-03 zero or negative price dropped
+	// 2022-01-06 zero or negative price repaired
+	// [10 10.1 10.1 10.2]
+}
+
+func ExampleIssue_Validate_undefinedReturns() {
+	days := []int{3, 4, 5, 6, 7, 10, 11, 12, 13, 14}
+	close := []float64{10, 10, 10.1, 10.2, 10.1, 0, 10.2, 10.3, 30, 10.4}
+	iss := testIssue(days, close, close)
+
+	// The zero price has no return, so it does not hide the spike on the 13th.
+	report := iss.Validate(ValidationRules{Policy: PolicyReport, SpikeSigma: 1.5})
+	for _, f := range report.Findings {
+		fmt.Printf("%s %s %s\n", f.Date.Format(DateFormat), f.Check, f.Action)
+	}
+
+	// Output:
+	// 2022-01-10 zero or negative price reported
+	// 2022-01-13 price spike reported
+}
+
+type invalidSource struct{}
+
+func (invalidSource) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*Group, error) {
+	return &Group{Name: name, Issues: []Issue{testIssue([]int{3, 4}, []float64{10, 0}, []float64{10, 0})}}, nil
+}
+
+func ExampleNewGroupFromSource() {
+	defer func(v *ValidationRules) { Validation = v }(Validation)
+	Validation = &ValidationRules{Policy: PolicyFail}
+	_, err := NewGroupFromSource(context.Background(), invalidSource{}, false, "", "testGroup", nil)
+	fmt.Printf("%+v\n", err)
+
+	Validation = &ValidationRules{Policy: PolicyRepair}
+	group, err := NewGroupFromSource(context.Background(), invalidSource{}, false, "", "testGroup", nil)
+	fmt.Printf("%+v %+v\n", err, group.Issues[0].DatasetAsColumns.Close)
+
+	// Output:
+	// validation failed for symbols: [test]
+	// <nil> [10 10]
+}
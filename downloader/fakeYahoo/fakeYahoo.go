@@ -0,0 +1,258 @@
+// Package fakeYahoo implements an httptest server that stands in for the finance.yahoo chart
+// and quoteSummary APIs, so downloads can be tested offline. Bars are generated for any symbol,
+// date range, and interval, on the trading days of dl.Calendar, profiles are generated for any
+// symbol, and failures can be injected per symbol.
+package fakeYahoo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+// Server is the fake chart API. Use URL (from httptest.Server) as the base URL of the source.
+type Server struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	failures map[string][]failure
+	requests map[string]int
+}
+
+// Failure is a failure that can be injected with Server.Fail.
+type Failure string
+
+// failure is an injected Failure and the number of requests remaining to fail; remaining < 0
+// fails all requests.
+type failure struct {
+	Failure   Failure
+	remaining int
+}
+
+const (
+	// ChartPath is the path of the chart API; the symbol is appended.
+	ChartPath = "/v8/finance/chart/"
+	// ProfilePath is the path of the quoteSummary API; the symbol is appended.
+	ProfilePath = "/v10/finance/quoteSummary/"
+
+	// EmptyResult replies with an empty result list.
+	EmptyResult Failure = "empty result"
+	// NotFound replies with HTTP 404.
+	NotFound Failure = "not found"
+	// NullArrays replies with null for the quote and adjclose arrays.
+	NullArrays Failure = "null arrays"
+	// NullValues replies with null for the prices of every other bar.
+	NullValues Failure = "null values"
+	// ServerError replies with HTTP 500.
+	ServerError Failure = "server error"
+	// TooManyRequests replies with HTTP 429 and Retry-After: 0.
+	TooManyRequests Failure = "too many requests"
+	// TruncatedJSON replies with the first half of the JSON.
+	TruncatedJSON Failure = "truncated JSON"
+)
+
+// New is a factory for Server; the server is started and must be closed by the caller.
+func New() *Server {
+	srv := &Server{failures: make(map[string][]failure), requests: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(ChartPath, srv.handleChart)
+	mux.HandleFunc(ProfilePath, srv.handleProfile)
+	srv.Server = httptest.NewServer(mux)
+	return srv
+}
+
+// Fail injects f for the next times requests for symbol; times <= 0 fails all requests.
+// Failures for a symbol are applied in the order they were added.
+func (srv *Server) Fail(symbol string, f Failure, times int) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	if times <= 0 {
+		times = -1
+	}
+	srv.failures[symbol] = append(srv.failures[symbol], failure{Failure: f, remaining: times})
+}
+
+// Requests returns the number of requests received for symbol.
+func (srv *Server) Requests(symbol string) int {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	return srv.requests[symbol]
+}
+
+// Bars returns the bar times generated for the range [start, end) and interval; see
+// dl.Interval.Bars.
+func Bars(start time.Time, end time.Time, interval dl.Interval) []time.Time {
+	return interval.Bars(start, end)
+}
+
+// Price returns the close generated for bar index i of symbol; each symbol has a different
+// starting price that rises 0.1% per bar.
+func Price(symbol string, i int) float64 {
+	base := 50.0
+	for _, b := range []byte(symbol) {
+		base += float64(b % 50)
+	}
+	return float64(int(base*(1+0.001*float64(i))*100)) / 100
+}
+
+// handleChart serves ChartPath<symbol>?period1=<unix>&period2=<unix>&interval=<interval>
+func (srv *Server) handleChart(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimPrefix(r.URL.Path, ChartPath)
+	if symbol == r.URL.Path || symbol == "" {
+		http.NotFound(w, r)
+		return
+	}
+	f := srv.nextFailure(symbol)
+
+	switch f {
+	case NotFound:
+		http.NotFound(w, r)
+		return
+	case ServerError:
+		http.Error(w, "fake server error", http.StatusInternalServerError)
+		return
+	case TooManyRequests:
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	period1, err1 := strconv.ParseInt(r.URL.Query().Get("period1"), 10, 64)
+	period2, err2 := strconv.ParseInt(r.URL.Query().Get("period2"), 10, 64)
+	interval, err3 := dl.ParseInterval(r.URL.Query().Get("interval"))
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, fmt.Sprintf("invalid query: %s", r.URL.RawQuery), http.StatusBadRequest)
+		return
+	}
+
+	body, err := json.Marshal(chart(symbol, time.Unix(period1, 0), time.Unix(period2, 0), interval, f))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if f == TruncatedJSON {
+		body = body[:len(body)/2]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// ExpenseRatio returns the expense ratio (in percent) in the profile generated for symbol.
+func ExpenseRatio(symbol string) float64 {
+	sum := 0
+	for _, b := range []byte(symbol) {
+		sum += int(b)
+	}
+	return float64(sum%20+1) / 100
+}
+
+// handleProfile serves ProfilePath<symbol>; every symbol is an ETF with the expense ratio from
+// ExpenseRatio and two holdings. The failures of the symbol are shared with handleChart.
+func (srv *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimPrefix(r.URL.Path, ProfilePath)
+	if symbol == "" {
+		http.NotFound(w, r)
+		return
+	}
+	f := srv.nextFailure(symbol)
+
+	switch f {
+	case NotFound:
+		http.NotFound(w, r)
+		return
+	case ServerError:
+		http.Error(w, "fake server error", http.StatusInternalServerError)
+		return
+	case TooManyRequests:
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
+
+	results := []interface{}{}
+	if f != EmptyResult {
+		raw := func(v float64) map[string]interface{} { return map[string]interface{}{"raw": v} }
+		results = append(results, map[string]interface{}{
+			"price":         map[string]interface{}{"longName": "Fake " + strings.ToUpper(symbol) + " ETF", "quoteType": "ETF", "currency": "USD"},
+			"summaryDetail": map[string]interface{}{"yield": raw(0.015), "totalAssets": raw(1e9 * Price(symbol, 0)), "beta": raw(1)},
+			"fundProfile":   map[string]interface{}{"feesExpensesInvestment": map[string]interface{}{"annualReportExpenseRatio": raw(ExpenseRatio(symbol) / 100)}},
+			"topHoldings": map[string]interface{}{"holdings": []interface{}{
+				map[string]interface{}{"symbol": "AAPL", "holdingName": "Apple Inc", "holdingPct": raw(0.07)},
+				map[string]interface{}{"symbol": "MSFT", "holdingName": "Microsoft Corp", "holdingPct": raw(0.06)},
+			}},
+		})
+	}
+	body, err := json.Marshal(map[string]interface{}{"quoteSummary": map[string]interface{}{"result": results, "error": nil}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if f == TruncatedJSON {
+		body = body[:len(body)/2]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// nextFailure counts the request and returns the Failure to inject, if any.
+func (srv *Server) nextFailure(symbol string) Failure {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.requests[symbol]++
+	failures := srv.failures[symbol]
+	for len(failures) > 0 {
+		if failures[0].remaining == 0 {
+			failures = failures[1:]
+			continue
+		}
+		if failures[0].remaining > 0 {
+			failures[0].remaining--
+		}
+		srv.failures[symbol] = failures
+		return failures[0].Failure
+	}
+	srv.failures[symbol] = failures
+	return ""
+}
+
+// chart returns the chart API reply, in the same layout as finance.yahoo.
+func chart(symbol string, start time.Time, end time.Time, interval dl.Interval, f Failure) map[string]interface{} {
+	if f == EmptyResult {
+		return map[string]interface{}{"chart": map[string]interface{}{"result": []interface{}{}, "error": nil}}
+	}
+
+	bars := Bars(start, end, interval)
+	timestamps := make([]int64, len(bars))
+	open := make([]interface{}, len(bars))
+	high := make([]interface{}, len(bars))
+	low := make([]interface{}, len(bars))
+	closePrice := make([]interface{}, len(bars))
+	volume := make([]interface{}, len(bars))
+	for i, t := range bars {
+		timestamps[i] = t.Unix()
+		if f == NullValues && i%2 == 1 {
+			continue
+		}
+		c := Price(symbol, i)
+		open[i], high[i], low[i], closePrice[i], volume[i] = Price(symbol, i-1), c+0.5, c-0.5, c, 1000+i
+	}
+
+	var quote, adjClose interface{}
+	if f != NullArrays {
+		quote = []interface{}{map[string]interface{}{"open": open, "high": high, "low": low, "close": closePrice, "volume": volume}}
+		adjClose = []interface{}{map[string]interface{}{"adjclose": closePrice}}
+	}
+	meta := map[string]interface{}{"currency": "USD", "symbol": strings.ToUpper(symbol), "exchangeName": "FAKE",
+		"instrumentType": "ETF", "timezone": "EST", "exchangeTimezoneName": dl.Calendar.Location.String(),
+		"regularMarketPrice": Price(symbol, len(bars)-1), "firstTradeDate": start.Unix(), "dataGranularity": string(interval)}
+	result := map[string]interface{}{"meta": meta, "timestamp": timestamps,
+		"indicators": map[string]interface{}{"quote": quote, "adjclose": adjClose}}
+	return map[string]interface{}{"chart": map[string]interface{}{"result": []interface{}{result}, "error": nil}}
+}
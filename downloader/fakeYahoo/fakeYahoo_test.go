@@ -0,0 +1,53 @@
+package fakeYahoo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+func ExampleBars() {
+	start := time.Date(2024, time.March, 27, 0, 0, 0, 0, dl.Calendar.Location)
+	end := time.Date(2024, time.April, 9, 0, 0, 0, 0, dl.Calendar.Location)
+	// March 29 2024 is Good Friday.
+	for _, interval := range []dl.Interval{dl.Interval1d, dl.Interval1wk, dl.Interval1mo} {
+		bars := Bars(start, end, interval)
+		fmt.Printf("%s %d %s %s\n", interval, len(bars), bars[0].Format(time.RFC3339), bars[len(bars)-1].Format(time.RFC3339))
+	}
+	bars := Bars(start, start.AddDate(0, 0, 1), dl.Interval1h)
+	fmt.Printf("%s %d %s %s\n", dl.Interval1h, len(bars), bars[0].Format(time.RFC3339), bars[len(bars)-1].Format(time.RFC3339))
+
+	// Output:
+	// 1d 8 2024-03-27T09:30:00-04:00 2024-04-08T09:30:00-04:00
+	// 1wk 3 2024-03-27T09:30:00-04:00 2024-04-08T09:30:00-04:00
+	// 1mo 2 2024-03-27T09:30:00-04:00 2024-04-01T09:30:00-04:00
+	// 1h 7 2024-03-27T09:30:00-04:00 2024-03-27T15:30:00-04:00
+}
+
+func ExampleServer_Fail() {
+	srv := New()
+	defer srv.Close()
+	srv.Fail("dia", TooManyRequests, 1)
+	srv.Fail("dia", NotFound, 1)
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(srv.URL + ChartPath + "dia?period1=1711497600&period2=1711584000&interval=1d")
+		if err != nil {
+			fmt.Printf("%+v\n", err)
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		fmt.Printf("%d %t\n", resp.StatusCode, len(body) > 100)
+	}
+	fmt.Printf("requests: %d\n", srv.Requests("dia"))
+
+	// Output:
+	// 429 false
+	// 404 false
+	// 200 true
+	// requests: 3
+}
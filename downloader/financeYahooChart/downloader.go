@@ -3,8 +3,12 @@
 package financeYahooChart
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/paulfdunn/go-helper/logh/v2"
@@ -22,45 +26,108 @@ type YfChart struct {
 }
 
 type YfResult struct {
-	Meta       struct{} `json:"meta"`
-	Timestamp  []int    `json:"timestamp"`
+	Meta       YfMeta `json:"meta"`
+	Timestamp  []int  `json:"timestamp"`
 	Indicators struct {
 		Quote    []YfQuote    `json:"quote"`
 		AdjClose []YfAdjClose `json:"adjclose"`
 	} `json:"indicators"`
+	// Events are keyed by the event timestamp.
+	Events struct {
+		Dividends map[string]YfDividend `json:"dividends"`
+		Splits    map[string]YfSplit    `json:"splits"`
+	} `json:"events"`
 }
 
+type YfMeta struct {
+	Currency             string  `json:"currency"`
+	Symbol               string  `json:"symbol"`
+	ExchangeName         string  `json:"exchangeName"`
+	FullExchangeName     string  `json:"fullExchangeName"`
+	InstrumentType       string  `json:"instrumentType"`
+	FirstTradeDate       int     `json:"firstTradeDate"`
+	Timezone             string  `json:"timezone"`
+	ExchangeTimezoneName string  `json:"exchangeTimezoneName"`
+	RegularMarketPrice   float64 `json:"regularMarketPrice"`
+	DataGranularity      string  `json:"dataGranularity"`
+}
+
+// YfQuote values are pointers as Yahoo sends null for missing values.
 type YfQuote struct {
-	Close  []float64 `json:"close"`
-	High   []float64 `json:"high"`
-	Low    []float64 `json:"low"`
-	Open   []float64 `json:"open"`
-	Volume []int     `json:"volume"`
+	Close  []*float64 `json:"close"`
+	High   []*float64 `json:"high"`
+	Low    []*float64 `json:"low"`
+	Open   []*float64 `json:"open"`
+	Volume []*float64 `json:"volume"`
 }
 
 type YfAdjClose struct {
-	AdjClose []float64 `json:"adjclose"`
+	AdjClose []*float64 `json:"adjclose"`
+}
+
+type YfDividend struct {
+	Amount float64 `json:"amount"`
+	Date   int     `json:"date"`
+}
+
+type YfSplit struct {
+	Date        int     `json:"date"`
+	Numerator   float64 `json:"numerator"`
+	Denominator float64 `json:"denominator"`
+	SplitRatio  string  `json:"splitRatio"`
 }
 
+const (
+	// SourceName is the name used to register this source with the downloader.
+	SourceName = "financeYahooChart"
+)
+
 var (
 	appName string
 	// lp      func(level logh.LoghLevel, v ...interface{})
 	lpf func(level logh.LoghLevel, format string, v ...interface{})
 
+	// ChartBaseURL is the scheme and host of the chart API; tests replace it with the URL of a
+	// fakeYahoo.Server.
+	ChartBaseURL = "https://query2.finance.yahoo.com"
+
 	// See yfinance for parameter reference:
 	// https://github.com/ranaroussi/yfinance/blob/3fe87cb1326249cb6a2ce33e9e23c5fd564cf54b/yfinance/scrapers/history.py#L13
-	yahooURL = "https://query2.finance.yahoo.com/v8/finance/chart/%s?period1=%d&period2=%d&" +
-		"interval=1d"
+	// The base URL and interval are set by intervalURL.
+	yahooURL = "%s/v8/finance/chart/%%s?period1=%%d&period2=%%d&" +
+		"interval=%s&events=div,splits"
 )
 
+// Source implements dl.Downloader and is registered with the downloader as SourceName.
+type Source struct{}
+
 func Init(appNameInit string) {
 	appName = appNameInit
 	// lp = logh.Map[appName].Println
 	lpf = logh.Map[appName].Printf
+
+	if err := dl.Register(SourceName, Source{}); err != nil {
+		lpf(logh.Warning, "registering source: %+v", err)
+	}
 }
 
 func NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
-	return dl.NewGroup(liveData, dataFilePath, name, symbols, yahooURL, urlCollectionDataToGroup)
+	return dl.NewGroup(liveData, dataFilePath, name, symbols, intervalURL(dl.BarInterval), urlCollectionDataToGroup)
+}
+
+// intervalURL returns yahooURL using ChartBaseURL and requesting bars of interval.
+func intervalURL(interval dl.Interval) string {
+	return fmt.Sprintf(yahooURL, ChartBaseURL, interval)
+}
+
+// NewGroup implements dl.Downloader.
+func (Source) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	return NewGroup(liveData, dataFilePath, name, symbols)
+}
+
+// NewGroupContext implements dl.ContextDownloader.
+func (Source) NewGroupContext(ctx context.Context, liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	return dl.NewGroupContext(ctx, liveData, dataFilePath, name, symbols, intervalURL(dl.BarInterval), urlCollectionDataToGroup)
 }
 
 // urlCollectionDataToGroup processes raw data into a Group
@@ -81,10 +148,14 @@ func urlCollectionDataToGroup(urlData []httph.URLCollectionData, urlSymbolMap ma
 			lpf(logh.Error, "unmarshal of data failed, symbol: %s, body:%s, error:%s", symbol, string(ucd.Bytes), err)
 			return nil, err
 		}
-		if yfc.Chart.Result == nil {
+		if len(yfc.Chart.Result) == 0 || len(yfc.Chart.Result[0].Timestamp) == 0 {
 			lpf(logh.Error, "zero length data, symbol:%5s, body:%s", symbol, string(ucd.Bytes))
 			return nil, errors.New("zero length data")
 		}
+		if err := checkLengths(yfc.Chart.Result[0]); err != nil {
+			lpf(logh.Error, "symbol:%5s, %+v", symbol, err)
+			return nil, err
+		}
 
 		yfcLen := len(yfc.Chart.Result[0].Timestamp)
 		Date := make([]time.Time, 0, yfcLen)
@@ -103,37 +174,101 @@ func urlCollectionDataToGroup(urlData []httph.URLCollectionData, urlSymbolMap ma
 			AdjOpen: AdjOpen, AdjHigh: AdjHigh, AdjLow: AdjLow, AdjClose: AdjClose, AdjVolume: AdjVolume}
 		dateFirst := time.Unix(int64(yfc.Chart.Result[0].Timestamp[0]), 0)
 		dateLast := time.Unix(int64(yfc.Chart.Result[0].Timestamp[yfcLen-1]), 0)
+		missing := 0
+		quote := yfc.Chart.Result[0].Indicators.Quote[0]
 		for i := 0; i < yfcLen; i++ {
 			date := time.Unix(int64(yfc.Chart.Result[0].Timestamp[i]), 0)
-			if yfc.Chart.Result[0].Indicators.Quote[0].Open[i] == 0 {
+			open, high, low, closePrice, adjClose := value(quote.Open[i]), value(quote.High[i]), value(quote.Low[i]),
+				value(quote.Close[i]), value(yfc.Chart.Result[0].Indicators.AdjClose[0].AdjClose[i])
+			if open == 0 && !dl.Calendar.IsTradingDay(date) {
 				// Some issues have weekend and holiday dates with price data that is all zeros.
-				if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
-					continue
-				}
-				// Print is noisy for ^tnx - only print for debugging.
-				// lpf(logh.Warning, "Open is zero on weekday (may be holiday), symbol: %s, date: %s", symbol, date)
 				continue
 			}
-			datasetAsColumns.Date = append(datasetAsColumns.Date, time.Unix(int64(yfc.Chart.Result[0].Timestamp[i]), 0))
-			datasetAsColumns.Open = append(datasetAsColumns.Open, yfc.Chart.Result[0].Indicators.Quote[0].Open[i])
-			datasetAsColumns.High = append(datasetAsColumns.High, yfc.Chart.Result[0].Indicators.Quote[0].High[i])
-			datasetAsColumns.Low = append(datasetAsColumns.Low, yfc.Chart.Result[0].Indicators.Quote[0].Low[i])
-			datasetAsColumns.Close = append(datasetAsColumns.Close, yfc.Chart.Result[0].Indicators.Quote[0].Close[i])
-			datasetAsColumns.Volume = append(datasetAsColumns.Volume, float64(yfc.Chart.Result[0].Indicators.Quote[0].Volume[i]))
-			adj := yfc.Chart.Result[0].Indicators.AdjClose[0].AdjClose[i] / yfc.Chart.Result[0].Indicators.Quote[0].Close[i]
-			datasetAsColumns.AdjOpen = append(datasetAsColumns.AdjOpen, mathh.Round(yfc.Chart.Result[0].Indicators.Quote[0].Open[i]*adj, dl.InputPrecision))
-			datasetAsColumns.AdjHigh = append(datasetAsColumns.AdjHigh, mathh.Round(yfc.Chart.Result[0].Indicators.Quote[0].High[i]*adj, dl.InputPrecision))
-			datasetAsColumns.AdjLow = append(datasetAsColumns.AdjLow, mathh.Round(yfc.Chart.Result[0].Indicators.Quote[0].Low[i]*adj, dl.InputPrecision))
-			datasetAsColumns.AdjClose = append(datasetAsColumns.AdjClose, mathh.Round(yfc.Chart.Result[0].Indicators.AdjClose[0].AdjClose[i], dl.InputPrecision))
-			datasetAsColumns.AdjVolume = append(datasetAsColumns.AdjVolume, 0)
+			if math.IsNaN(open) || math.IsNaN(high) || math.IsNaN(low) || math.IsNaN(closePrice) || math.IsNaN(adjClose) ||
+				open == 0 || closePrice == 0 {
+				lpf(logh.Debug, "missing data, symbol: %s, date: %s", symbol, date)
+				missing++
+				datasetAsColumns.AppendMissing(date, dl.MissingBars)
+				continue
+			}
+			volume := value(quote.Volume[i])
+			if math.IsNaN(volume) {
+				volume = 0
+			}
+			adj := adjClose / closePrice
+			datasetAsColumns.AppendRow(dl.Data{Date: date, Open: open, High: high, Low: low, Close: closePrice, Volume: volume,
+				AdjOpen: mathh.Round(open*adj, dl.InputPrecision), AdjHigh: mathh.Round(high*adj, dl.InputPrecision),
+				AdjLow: mathh.Round(low*adj, dl.InputPrecision), AdjClose: mathh.Round(adjClose, dl.InputPrecision)})
+		}
+		if missing > 0 {
+			lpf(logh.Warning, "Missing bars; symbol:%5s, bars:%d, policy:%s", symbol, missing, dl.MissingBars)
 		}
 
-		issue := dl.Issue{Symbol: symbol, URL: ucd.URL, DatasetAsColumns: datasetAsColumns}
+		interval, err := dl.ParseInterval(yfc.Chart.Result[0].Meta.DataGranularity)
+		if err != nil {
+			// Data saved before intervals were supported did not always include the granularity.
+			interval = dl.BarInterval
+		}
+		issue := dl.Issue{Symbol: symbol, URL: ucd.URL, Interval: interval, Meta: meta(yfc.Chart.Result[0].Meta), DatasetAsColumns: datasetAsColumns,
+			Dividends: dividends(yfc.Chart.Result[0]), Splits: splits(yfc.Chart.Result[0])}
 		group.Issues = append(group.Issues, issue)
 
-		lpf(logh.Info, "Issue loaded; symbol:%5s, StartDate:%s, EndDate:%s, data points:%d",
-			issue.Symbol, dateFirst.Format(dl.DateFormat), dateLast.Format(dl.DateFormat), yfcLen-1)
+		lpf(logh.Info, "Issue loaded; symbol:%5s, StartDate:%s, EndDate:%s, data points:%d, dividends:%d, splits:%d",
+			issue.Symbol, dateFirst.Format(interval.DateFormat()), dateLast.Format(interval.DateFormat()), yfcLen-1, len(issue.Dividends), len(issue.Splits))
 	}
 
 	return group, nil
 }
+
+// checkLengths returns an error unless yfr has a value for every timestamp in each array.
+func checkLengths(yfr YfResult) error {
+	n := len(yfr.Timestamp)
+	if len(yfr.Indicators.Quote) == 0 || len(yfr.Indicators.AdjClose) == 0 {
+		return fmt.Errorf("missing quote or adjclose data, timestamps: %d", n)
+	}
+	q := yfr.Indicators.Quote[0]
+	for _, length := range []int{len(q.Open), len(q.High), len(q.Low), len(q.Close), len(q.Volume), len(yfr.Indicators.AdjClose[0].AdjClose)} {
+		if length != n {
+			return fmt.Errorf("incomplete data, timestamps: %d, values: %d", n, length)
+		}
+	}
+	return nil
+}
+
+// value returns *v, or NaN when v is nil (null).
+func value(v *float64) float64 {
+	if v == nil {
+		return math.NaN()
+	}
+	return *v
+}
+
+// dividends returns the dividend events in yfr, in Date ascending order.
+func dividends(yfr YfResult) []dl.Dividend {
+	var out []dl.Dividend
+	for _, d := range yfr.Events.Dividends {
+		out = append(out, dl.Dividend{Date: time.Unix(int64(d.Date), 0), Amount: d.Amount})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	return out
+}
+
+// meta converts yfm to dl.Meta.
+func meta(yfm YfMeta) dl.Meta {
+	m := dl.Meta{Currency: yfm.Currency, ExchangeName: yfm.ExchangeName, InstrumentType: yfm.InstrumentType,
+		Timezone: yfm.ExchangeTimezoneName, RegularMarketPrice: yfm.RegularMarketPrice}
+	if yfm.FirstTradeDate != 0 {
+		m.FirstTradeDate = time.Unix(int64(yfm.FirstTradeDate), 0)
+	}
+	return m
+}
+
+// splits returns the split events in yfr, in Date ascending order.
+func splits(yfr YfResult) []dl.Split {
+	var out []dl.Split
+	for _, s := range yfr.Events.Splits {
+		out = append(out, dl.Split{Date: time.Unix(int64(s.Date), 0), Numerator: s.Numerator, Denominator: s.Denominator})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	return out
+}
@@ -1,11 +1,16 @@
 package financeYahooChart
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/paulfdunn/go-helper/neth/v2/httph"
 	dl "github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/downloader/fakeYahoo"
 )
 
 const (
@@ -45,6 +50,15 @@ func ExampleNewGroup() {
 	//     {
 	//       "Symbol": "dia",
 	//       "URL": "https://query2.finance.yahoo.com/v8/finance/chart/dia?period1=1640995200\u0026period2=1641340800\u0026interval=1d",
+	//       "Interval": "1d",
+	//       "Meta": {
+	//         "Currency": "USD",
+	//         "ExchangeName": "PCX",
+	//         "InstrumentType": "ETF",
+	//         "Timezone": "America/New_York",
+	//         "RegularMarketPrice": 423.85,
+	//         "FirstTradeDate": "1998-01-20T07:30:00-07:00"
+	//       },
 	//       "Dataset": null,
 	//       "DatasetAsColumns": {
 	//         "Date": [
@@ -61,11 +75,24 @@ func ExampleNewGroup() {
 	//         "AdjLow": [340.81,345.43],
 	//         "AdjClose": [343.99,346.05],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     },
 	//     {
 	//       "Symbol": "qqq",
 	//       "URL": "https://query2.finance.yahoo.com/v8/finance/chart/qqq?period1=1640995200\u0026period2=1641340800\u0026interval=1d",
+	//       "Interval": "1d",
+	//       "Meta": {
+	//         "Currency": "USD",
+	//         "ExchangeName": "NGM",
+	//         "InstrumentType": "ETF",
+	//         "Timezone": "America/New_York",
+	//         "RegularMarketPrice": 521.22,
+	//         "FirstTradeDate": "1999-03-10T07:30:00-07:00"
+	//       },
 	//       "Dataset": null,
 	//       "DatasetAsColumns": {
 	//         "Date": [
@@ -82,8 +109,236 @@ func ExampleNewGroup() {
 	//         "AdjLow": [388.38,384.87],
 	//         "AdjClose": [393.08,387.98],
 	//         "AdjVolume": [0,0]
-	//       }
+	//       },
+	//       "Dividends": null,
+	//       "Splits": null,
+	//       "BaseCurrency": "",
+	//       "FXRate": null
 	//     }
-	//   ]
+	//   ],
+	//   "Failures": null,
+	//   "FX": null
 	// }
 }
+
+func Example_urlCollectionDataToGroup() {
+	body := `{"chart":{"result":[{"meta":{"currency":"USD","exchangeName":"PCX","instrumentType":"ETF"},
+	"timestamp":[1641220200,1641306600],
+	"indicators":{"quote":[{"open":[10,11],"high":[10,11],"low":[10,11],"close":[10,11],"volume":[100,100]}],
+	"adjclose":[{"adjclose":[10,11]}]},
+	"events":{"dividends":{"1641306600":{"amount":0.25,"date":1641306600},"1641220200":{"amount":0.5,"date":1641220200}},
+	"splits":{"1641306600":{"date":1641306600,"numerator":4,"denominator":1,"splitRatio":"4:1"}}}}]}}`
+	url := fmt.Sprintf(intervalURL(dl.Interval1d), "test", dl.EarliestDate, dl.LatestDate)
+	urlSymbolMap := map[string]string{dl.BaseURL(url): "test"}
+	group, err := urlCollectionDataToGroup([]httph.URLCollectionData{{URL: url, Bytes: []byte(body)}}, urlSymbolMap, "testGroup")
+	if err != nil {
+		fmt.Printf("error: %+v\n", err)
+		return
+	}
+
+	fmt.Println(group.Issues[0].Description())
+	for _, d := range group.Issues[0].Dividends {
+		fmt.Printf("dividend: %s %.2f\n", d.Date.Format(dl.DateFormat), d.Amount)
+	}
+	for _, s := range group.Issues[0].Splits {
+		fmt.Printf("split: %s %.0f:%.0f\n", s.Date.Format(dl.DateFormat), s.Numerator, s.Denominator)
+	}
+	// Output:
+	// test (ETF, USD, PCX)
+	// dividend: 2022-01-03 0.50
+	// dividend: 2022-01-04 0.25
+	// split: 2022-01-04 4:1
+}
+
+func Example_urlCollectionDataToGroup_missing() {
+	// The second bar has a null close, and the third bar a null volume.
+	body := `{"chart":{"result":[{"timestamp":[1641220200,1641306600,1641393000],
+	"indicators":{"quote":[{"open":[10,11,12],"high":[10,11,12],"low":[10,11,12],"close":[10,null,12],"volume":[100,100,null]}],
+	"adjclose":[{"adjclose":[9,null,11]}]}}]}}`
+	url := fmt.Sprintf(intervalURL(dl.Interval1d), "test", dl.EarliestDate, dl.LatestDate)
+	urlSymbolMap := map[string]string{dl.BaseURL(url): "test"}
+	missingBars := dl.MissingBars
+	defer func() { dl.MissingBars = missingBars }()
+
+	for _, policy := range []dl.MissingBarPolicy{dl.MissingDrop, dl.MissingForwardFill, dl.MissingMark} {
+		dl.MissingBars = policy
+		group, err := urlCollectionDataToGroup([]httph.URLCollectionData{{URL: url, Bytes: []byte(body)}}, urlSymbolMap, "testGroup")
+		if err != nil {
+			fmt.Printf("error: %+v\n", err)
+			return
+		}
+		dac := group.Issues[0].DatasetAsColumns
+		fmt.Printf("%-12s close:%v adjClose:%v volume:%v\n", policy, dac.Close, dac.AdjClose, dac.Volume)
+	}
+	// Output:
+	// drop         close:[10 12] adjClose:[9 11] volume:[100 0]
+	// forward-fill close:[10 10 12] adjClose:[9 9 11] volume:[100 0 0]
+	// mark         close:[10 NaN 12] adjClose:[9 NaN 11] volume:[100 NaN 0]
+}
+
+func ExampleNewGroup_offline() {
+	srv := fakeYahoo.New()
+	defer srv.Close()
+	srv.Fail("spy", fakeYahoo.TooManyRequests, 1)
+	srv.Fail("qqq", fakeYahoo.TruncatedJSON, 0)
+	srv.Fail("iwm", fakeYahoo.NullArrays, 0)
+	srv.Fail("ddm", fakeYahoo.EmptyResult, 0)
+	srv.Fail("qld", fakeYahoo.NullValues, 0)
+
+	baseURL, retry, rateLimit := ChartBaseURL, dl.Retry, dl.RateLimit
+	defer func() { ChartBaseURL, dl.Retry, dl.RateLimit = baseURL, retry, rateLimit }()
+	ChartBaseURL = srv.URL
+	dl.Retry = dl.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	dl.RateLimit = nil
+	dir, err := os.MkdirTemp("", "financeYahooChart")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	group, err := NewGroup(true, filepath.Join(dir, "offline"), "offline", []string{"dia", "spy", "qqq", "iwm", "ddm", "qld"})
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	for _, iss := range group.Issues {
+		fmt.Printf("%s %d %+v %s\n", iss.Description(), len(iss.DatasetAsColumns.Date), iss.DatasetAsColumns.Close, iss.Interval)
+	}
+	for _, failure := range group.Failures {
+		fmt.Printf("failed: %s\n", failure.Symbol)
+	}
+	fmt.Printf("requests: spy:%d qqq:%d\n", srv.Requests("spy"), srv.Requests("qqq"))
+
+	// Output:
+	// dia (ETF, USD, FAKE) 2 [102 102.1] 1d
+	// spy (ETF, USD, FAKE) 2 [98 98.09] 1d
+	// qld (ETF, USD, FAKE) 1 [71] 1d
+	// failed: qqq
+	// failed: iwm
+	// failed: ddm
+	// requests: spy:2 qqq:1
+}
+
+func ExampleNewGroup_missingMark() {
+	srv := fakeYahoo.New()
+	defer srv.Close()
+	srv.Fail("qld", fakeYahoo.NullValues, 0)
+
+	baseURL, missingBars, validation, rateLimit := ChartBaseURL, dl.MissingBars, dl.Validation, dl.RateLimit
+	defer func() {
+		ChartBaseURL, dl.MissingBars, dl.Validation, dl.RateLimit = baseURL, missingBars, validation, rateLimit
+	}()
+	ChartBaseURL = srv.URL
+	dl.RateLimit = nil
+	dir, err := os.MkdirTemp("", "financeYahooChart")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// Marked bars are NaN, which cannot be saved, so validation must repair or drop them.
+	dl.MissingBars = dl.MissingMark
+	for _, policy := range []dl.ValidationPolicy{dl.PolicyReport, dl.PolicyRepair} {
+		dl.Validation = &dl.ValidationRules{Policy: policy}
+		group, err := NewGroup(true, filepath.Join(dir, "mark"), "mark", []string{"qld"})
+		if err != nil {
+			fmt.Printf("%s: %+v\n", policy, err)
+			continue
+		}
+		fmt.Printf("%s: %+v\n", policy, group.Issues[0].DatasetAsColumns.Close)
+	}
+
+	// Output:
+	// report: missing bar policy: mark requires validation policy: repair or drop, validation policy: report
+	// repair: [71 71]
+}
+
+func ExampleSource_NewGroupContext() {
+	srv := fakeYahoo.New()
+	defer srv.Close()
+	srv.Fail("qqq", fakeYahoo.NotFound, 0)
+
+	baseURL, retry, rateLimit := ChartBaseURL, dl.Retry, dl.RateLimit
+	defer func() { ChartBaseURL, dl.Retry, dl.RateLimit = baseURL, retry, rateLimit }()
+	ChartBaseURL = srv.URL
+	dl.Retry = dl.RetryPolicy{MaxAttempts: 1}
+	dl.RateLimit = nil
+	dir, err := os.MkdirTemp("", "financeYahooChart")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	progress := func(symbol string, err error) {
+		fmt.Printf("progress: %s %t\n", symbol, err == nil)
+	}
+	ctx := dl.WithProgress(context.Background(), progress)
+	group, err := dl.NewGroupFromSource(ctx, Source{}, true, filepath.Join(dir, "progress"), "progress", []string{"dia", "qqq", "spy"})
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	fmt.Println(len(group.Issues), group.Failures[0].Symbol)
+
+	// A canceled download requests nothing, and saves nothing.
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = dl.NewGroupFromSource(ctx, Source{}, true, filepath.Join(dir, "canceled"), "canceled", []string{"dia"})
+	_, statErr := os.Stat(dl.IntervalKey(filepath.Join(dir, "canceled"), dl.BarInterval) + dl.GroupExtension)
+	fmt.Println(err, os.IsNotExist(statErr), srv.Requests("dia"))
+
+	// Output:
+	// progress: dia true
+	// progress: qqq false
+	// progress: spy true
+	// 2 qqq
+	// progress: dia false
+	// context canceled true 1
+}
+
+func ExampleNewProfiles() {
+	srv := fakeYahoo.New()
+	defer srv.Close()
+	srv.Fail("qqq", fakeYahoo.TruncatedJSON, 0)
+
+	baseURL, retry, rateLimit := ChartBaseURL, dl.Retry, dl.RateLimit
+	defer func() { ChartBaseURL, dl.Retry, dl.RateLimit = baseURL, retry, rateLimit }()
+	ChartBaseURL = srv.URL
+	dl.Retry = dl.RetryPolicy{MaxAttempts: 1}
+	dl.RateLimit = nil
+	dir, err := os.MkdirTemp("", "financeYahooChart")
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	symbols := []string{"dia", "qqq", "spy"}
+	if _, err := NewProfiles(context.Background(), true, filepath.Join(dir, "profiles"), symbols); err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	// Load the saved profiles.
+	profiles, err := NewProfiles(context.Background(), false, filepath.Join(dir, "profiles"), symbols)
+	if err != nil {
+		fmt.Printf("%+v\n", err)
+		return
+	}
+	dia := profiles["dia"]
+	fmt.Printf("%s %s %s expense ratio: %.2f%%, yield: %.2f%%, AUM: %.0f, holdings: %+v\n", dia.Name, dia.QuoteType,
+		dia.Currency, dia.ExpenseRatio, dia.DividendYield, dia.AUM, dia.Holdings)
+
+	group := dl.Group{Issues: []dl.Issue{{Symbol: "dia"}, {Symbol: "qqq"}, {Symbol: "spy"}}}
+	group.SetProfiles(profiles)
+	for _, iss := range group.SortByProfile(func(p dl.Profile) float64 { return -p.ExpenseRatio }) {
+		fmt.Printf("%s %.2f%%\n", iss.Symbol, iss.Profile.ExpenseRatio)
+	}
+
+	// Output:
+	// Fake DIA ETF ETF USD expense ratio: 0.03%, yield: 1.50%, AUM: 102000000000, holdings: [{Symbol:aapl Name:Apple Inc Weight:7} {Symbol:msft Name:Microsoft Corp Weight:6}]
+	// dia 0.03%
+	// spy 0.09%
+}
@@ -0,0 +1,131 @@
+package financeYahooChart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/paulfdunn/go-helper/neth/v2/httph"
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+type YfQuoteSummaryObj struct {
+	QuoteSummary YfQuoteSummary `json:"quoteSummary"`
+}
+
+type YfQuoteSummary struct {
+	Result []YfQuoteSummaryResult `json:"result"`
+}
+
+// YfQuoteSummaryResult holds the quoteSummary modules requested by profileURL; modules that
+// do not apply to the Issue (I.E. topHoldings for a stock) are missing.
+type YfQuoteSummaryResult struct {
+	Price struct {
+		LongName  string `json:"longName"`
+		QuoteType string `json:"quoteType"`
+		Currency  string `json:"currency"`
+	} `json:"price"`
+	SummaryProfile struct {
+		Sector   string `json:"sector"`
+		Industry string `json:"industry"`
+	} `json:"summaryProfile"`
+	SummaryDetail struct {
+		// Yield is the dividend yield of funds, and DividendYield of stocks.
+		Yield         YfValue `json:"yield"`
+		DividendYield YfValue `json:"dividendYield"`
+		TotalAssets   YfValue `json:"totalAssets"`
+		MarketCap     YfValue `json:"marketCap"`
+		TrailingPE    YfValue `json:"trailingPE"`
+		ForwardPE     YfValue `json:"forwardPE"`
+		Beta          YfValue `json:"beta"`
+	} `json:"summaryDetail"`
+	DefaultKeyStatistics struct {
+		PriceToBook              YfValue `json:"priceToBook"`
+		TrailingEps              YfValue `json:"trailingEps"`
+		AnnualReportExpenseRatio YfValue `json:"annualReportExpenseRatio"`
+	} `json:"defaultKeyStatistics"`
+	FundProfile struct {
+		FeesExpensesInvestment struct {
+			AnnualReportExpenseRatio YfValue `json:"annualReportExpenseRatio"`
+		} `json:"feesExpensesInvestment"`
+	} `json:"fundProfile"`
+	TopHoldings struct {
+		Holdings []struct {
+			Symbol      string  `json:"symbol"`
+			HoldingName string  `json:"holdingName"`
+			HoldingPct  YfValue `json:"holdingPct"`
+		} `json:"holdings"`
+	} `json:"topHoldings"`
+}
+
+// YfValue is a quoteSummary number; Yahoo sends {"raw": 0.0016, "fmt": "0.16%"}, or {} when the
+// value is unknown.
+type YfValue struct {
+	Raw float64 `json:"raw"`
+}
+
+const (
+	// profileURL is the quoteSummary API; the base URL is ChartBaseURL.
+	profileURL = "%s/v10/finance/quoteSummary/%%s?modules=price,summaryProfile,summaryDetail," +
+		"defaultKeyStatistics,fundProfile,topHoldings"
+)
+
+// NewProfiles downloads (liveData == true) or loads the Profiles of symbols; see dl.NewProfiles.
+func NewProfiles(ctx context.Context, liveData bool, dataFilePath string, symbols []string) (map[string]dl.Profile, error) {
+	return dl.NewProfiles(ctx, liveData, dataFilePath, symbols, fmt.Sprintf(profileURL, ChartBaseURL), urlCollectionDataToProfile)
+}
+
+// NewProfiles implements dl.ProfileDownloader.
+func (Source) NewProfiles(ctx context.Context, liveData bool, dataFilePath string, symbols []string) (map[string]dl.Profile, error) {
+	return NewProfiles(ctx, liveData, dataFilePath, symbols)
+}
+
+// urlCollectionDataToProfile processes raw quoteSummary data into a Profile. Ratios are
+// converted to percent.
+func urlCollectionDataToProfile(ucd httph.URLCollectionData) (dl.Profile, error) {
+	yqs := YfQuoteSummaryObj{}
+	if err := json.Unmarshal(ucd.Bytes, &yqs); err != nil {
+		return dl.Profile{}, fmt.Errorf("unmarshal profile failed, URL: %s, error: %w", ucd.URL, err)
+	}
+	if len(yqs.QuoteSummary.Result) == 0 {
+		return dl.Profile{}, errors.New("no profile data in result, URL: " + ucd.URL)
+	}
+	r := yqs.QuoteSummary.Result[0]
+
+	profile := dl.Profile{
+		Name:          r.Price.LongName,
+		QuoteType:     r.Price.QuoteType,
+		Currency:      r.Price.Currency,
+		ExpenseRatio:  r.FundProfile.FeesExpensesInvestment.AnnualReportExpenseRatio.percent(),
+		DividendYield: r.SummaryDetail.Yield.percent(),
+		AUM:           r.SummaryDetail.TotalAssets.Raw,
+		MarketCap:     r.SummaryDetail.MarketCap.Raw,
+		Sector:        r.SummaryProfile.Sector,
+		Industry:      r.SummaryProfile.Industry,
+		TrailingPE:    r.SummaryDetail.TrailingPE.Raw,
+		ForwardPE:     r.SummaryDetail.ForwardPE.Raw,
+		PriceToBook:   r.DefaultKeyStatistics.PriceToBook.Raw,
+		TrailingEPS:   r.DefaultKeyStatistics.TrailingEps.Raw,
+		Beta:          r.SummaryDetail.Beta.Raw,
+	}
+	if profile.ExpenseRatio == 0 {
+		profile.ExpenseRatio = r.DefaultKeyStatistics.AnnualReportExpenseRatio.percent()
+	}
+	if profile.DividendYield == 0 {
+		profile.DividendYield = r.SummaryDetail.DividendYield.percent()
+	}
+	for _, holding := range r.TopHoldings.Holdings {
+		profile.Holdings = append(profile.Holdings, dl.Holding{Symbol: strings.ToLower(holding.Symbol),
+			Name: holding.HoldingName, Weight: holding.HoldingPct.percent()})
+	}
+	return profile, nil
+}
+
+// percent returns the ratio v in percent, rounded to remove the floating point error of the
+// conversion; I.E. 0.0016 is 0.16.
+func (v YfValue) percent() float64 {
+	return math.Round(v.Raw*1e8) / 1e6
+}
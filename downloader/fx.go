@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+)
+
+// minorUnit is a currency quoted in a fraction of another currency; I.E. London listings are
+// quoted in GBp (pence).
+type minorUnit struct {
+	Currency string
+	Scale    float64
+}
+
+var (
+	// BaseCurrency, when not empty, is the currency (I.E. USD) to which NewGroup converts every
+	// Issue; see Group.ConvertCurrency.
+	BaseCurrency string
+
+	minorUnits = map[string]minorUnit{
+		"GBp": {Currency: "GBP", Scale: 0.01},
+		"GBX": {Currency: "GBP", Scale: 0.01},
+		"ILA": {Currency: "ILS", Scale: 0.01},
+		"ZAc": {Currency: "ZAR", Scale: 0.01},
+	}
+)
+
+// FXSymbol returns the Yahoo symbol of the FX pair giving the price of one from in units of to;
+// I.E. FXSymbol("EUR", "USD") == "EURUSD=X"
+func FXSymbol(from string, to string) string {
+	return strings.ToUpper(from) + strings.ToUpper(to) + "=X"
+}
+
+// ConvertCurrency sets BaseCurrency and FXRate on every Issue in grp, using the FX pairs in
+// grp.FX. FXRate[i] is the value of one unit of the Issue currency in base, on Date[i]; the
+// rate of the most recent FX bar on or before the date is used. Issues with no currency, or
+// the base currency, have a rate of 1. An error is returned listing the currencies for which
+// there is no FX data; those Issues are not converted.
+func (grp *Group) ConvertCurrency(base string) error {
+	fx := make(map[string]Issue)
+	for _, iss := range grp.FX {
+		fx[iss.Symbol] = iss
+	}
+
+	var missing []string
+	for i := range grp.Issues {
+		iss := &grp.Issues[i]
+		iss.BaseCurrency, iss.FXRate = "", nil
+		currency, scale := majorCurrency(iss.Meta.Currency)
+		rates := make([]float64, len(iss.DatasetAsColumns.Date))
+		if currency == "" || strings.EqualFold(currency, base) {
+			for j := range rates {
+				rates[j] = scale
+			}
+		} else {
+			pair, ok := fx[FXSymbol(currency, base)]
+			if !ok || len(pair.DatasetAsColumns.Date) == 0 {
+				missing = append(missing, currency)
+				continue
+			}
+			alignRates(rates, iss.DatasetAsColumns, pair.DatasetAsColumns, scale)
+		}
+		iss.BaseCurrency, iss.FXRate = base, rates
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no FX data for currencies: %v, base currency: %s", missing, base)
+	}
+	return nil
+}
+
+// FXSymbols returns the FX pairs needed to convert the Issues in grp to base.
+func (grp Group) FXSymbols(base string) []string {
+	var symbols []string
+	for _, iss := range grp.Issues {
+		currency, _ := majorCurrency(iss.Meta.Currency)
+		if currency == "" || strings.EqualFold(currency, base) {
+			continue
+		}
+		symbol := FXSymbol(currency, base)
+		if !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// alignRates sets rates[i] to the AdjClose of the most recent fx bar on or before the day of
+// dac.Date[i], multiplied by scale. Dates before the first fx bar use the first fx bar.
+func alignRates(rates []float64, dac DatasetAsColumns, fx DatasetAsColumns, scale float64) {
+	j := 0
+	for i, date := range dac.Date {
+		day := date.Format(DateFormat)
+		for j < len(fx.Date)-1 && fx.Date[j+1].Format(DateFormat) <= day {
+			j++
+		}
+		rates[i] = fx.AdjClose[j] * scale
+	}
+}
+
+// convertGroup downloads the FX pairs needed to convert group to BaseCurrency, then converts
+// group. Errors are logged, and the Issues that could not be converted are left in local
+// currency.
+func convertGroup(ctx context.Context, group *Group, url string, callbackURLCollectionDataToGroup URLCollectionDataToGroup) {
+	if symbols := group.FXSymbols(BaseCurrency); len(symbols) > 0 {
+		urls, urlSymbolMap := GenerateURLs(symbols, url)
+		fx, err := urlCollectionDataToGroupPartial(collectGroup(ctx, urls, urlSymbolMap), urlSymbolMap, group.Name,
+			callbackURLCollectionDataToGroup)
+		if err != nil {
+			lpf(logh.Error, "downloading FX pairs failed, group: %s, error: %+v", group.Name, err)
+		} else {
+			group.FX = fx.Issues
+		}
+	}
+	convertCurrency(group)
+}
+
+// convertCurrency converts group to BaseCurrency; errors are logged, as the Issues that could
+// not be converted are left in local currency.
+func convertCurrency(group *Group) {
+	if err := group.ConvertCurrency(BaseCurrency); err != nil {
+		lpf(logh.Error, "converting currency failed, Issues are left in local currency, group: %s, error: %+v", group.Name, err)
+	}
+}
+
+// majorCurrency returns the currency of minor units (I.E. GBP for GBp) and the scale to
+// convert to that currency; other currencies are returned unchanged with a scale of 1.
+func majorCurrency(currency string) (string, float64) {
+	if mu, ok := minorUnits[currency]; ok {
+		return mu.Currency, mu.Scale
+	}
+	return currency, 1
+}
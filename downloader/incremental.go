@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-helper/mathh/v2"
+)
+
+// newGroupIncremental loads the Group saved by the prior call to NewGroup, then only requests
+// bars starting at the last bar of each Issue. The last prior bar is requested again and compared
+// to the newly downloaded bar; if Close or AdjClose changed, a split or dividend changed past
+// adjusted prices and all data for that symbol is downloaded again. Symbols that are not in the
+// prior data, or that failed, are also downloaded in full. Symbols that fail the full download
+// are recorded in Group.Failures.
+// The raw data file is not updated, as it would only hold the new bars; use the saved Group.
+func newGroupIncremental(ctx context.Context, dataFilePath string, name string, symbols []string, url string,
+	callbackURLCollectionDataToGroup URLCollectionDataToGroup) (*Group, error) {
+	prior, err := LoadGroupFromFile(dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	priorIssues := make(map[string]Issue)
+	for _, iss := range prior.Issues {
+		if len(iss.DatasetAsColumns.Date) > 0 {
+			priorIssues[iss.Symbol] = iss
+		}
+	}
+
+	var fullSymbols []string
+	var urls []string
+	urlSymbolMap := make(map[string]string)
+	for _, symbol := range symbols {
+		iss, ok := priorIssues[symbol]
+		if !ok {
+			fullSymbols = append(fullSymbols, symbol)
+			continue
+		}
+		last := iss.DatasetAsColumns.Date[len(iss.DatasetAsColumns.Date)-1]
+		symbolURLs, symbolURLMap := generateURLsFrom([]string{symbol}, url, last.Unix())
+		urls = append(urls, symbolURLs...)
+		for k, v := range symbolURLMap {
+			urlSymbolMap[k] = v
+		}
+	}
+
+	issues := make(map[string]Issue)
+	var failures []Failure
+	if len(urls) > 0 {
+		update, err := urlCollectionDataToGroupPartial(collectGroup(ctx, urls, urlSymbolMap), urlSymbolMap, name, callbackURLCollectionDataToGroup)
+		if err != nil {
+			return nil, err
+		}
+		for _, failure := range update.Failures {
+			fullSymbols = append(fullSymbols, failure.Symbol)
+		}
+		for _, iss := range update.Issues {
+			merged, ok := mergeIssue(priorIssues[iss.Symbol], iss)
+			if !ok {
+				lpf(logh.Info, "prior data changed, downloading all data, symbol: %s", iss.Symbol)
+				fullSymbols = append(fullSymbols, iss.Symbol)
+				continue
+			}
+			issues[iss.Symbol] = merged
+		}
+	}
+
+	if len(fullSymbols) > 0 {
+		fullURLs, fullURLSymbolMap := GenerateURLs(fullSymbols, url)
+		full, err := urlCollectionDataToGroupPartial(collectGroup(ctx, fullURLs, fullURLSymbolMap), fullURLSymbolMap, name, callbackURLCollectionDataToGroup)
+		if err != nil {
+			return nil, err
+		}
+		for _, iss := range full.Issues {
+			issues[iss.Symbol] = iss
+		}
+		failures = full.Failures
+	}
+
+	group := &Group{Name: name, Failures: failures}
+	for _, symbol := range symbols {
+		if iss, ok := issues[symbol]; ok {
+			group.Issues = append(group.Issues, iss)
+		}
+	}
+	if len(group.Issues) == 0 {
+		return nil, fmt.Errorf("no data loaded for group: %s", name)
+	}
+	return group, nil
+}
+
+// mergeIssue appends the bars in update that are after the last bar in prior. The first bar
+// in update must be the last bar in prior, with the same Close and AdjClose (at InputPrecision);
+// otherwise ok == false and the prior data should be downloaded again.
+func mergeIssue(prior Issue, update Issue) (merged Issue, ok bool) {
+	priorDAC := prior.DatasetAsColumns
+	updateDAC := update.DatasetAsColumns
+	if len(priorDAC.Date) == 0 || len(updateDAC.Date) == 0 {
+		return Issue{}, false
+	}
+
+	last := len(priorDAC.Date) - 1
+	if !updateDAC.Date[0].Equal(priorDAC.Date[last]) {
+		return Issue{}, false
+	}
+	if mathh.Round(updateDAC.Close[0], InputPrecision) != mathh.Round(priorDAC.Close[last], InputPrecision) ||
+		mathh.Round(updateDAC.AdjClose[0], InputPrecision) != mathh.Round(priorDAC.AdjClose[last], InputPrecision) {
+		return Issue{}, false
+	}
+
+	merged = update
+	merged.DatasetAsColumns = priorDAC.Append(updateDAC.Slice(1, len(updateDAC.Date)))
+	merged.Dividends = append(slices.Clip(prior.Dividends), after(update.Dividends, priorDAC.Date[last], func(d Dividend) time.Time { return d.Date })...)
+	merged.Splits = append(slices.Clip(prior.Splits), after(update.Splits, priorDAC.Date[last], func(s Split) time.Time { return s.Date })...)
+	lpf(logh.Info, "Issue updated; symbol:%5s, new data points:%d", merged.Symbol, len(updateDAC.Date)-1)
+	return merged, true
+}
+
+// after returns the events that are after date.
+func after[E any](events []E, date time.Time, eventDate func(E) time.Time) []E {
+	var out []E
+	for _, e := range events {
+		if eventDate(e).After(date) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package downloader
+
+import (
+	"fmt"
+	"time"
+)
+
+// Interval is the time covered by each bar (Data) of an Issue, using the Yahoo names.
+// The zero value is treated as Interval1d, as data saved before intervals were supported is daily.
+type Interval string
+
+const (
+	Interval1m  Interval = "1m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval1h  Interval = "1h"
+	Interval1d  Interval = "1d"
+	Interval1wk Interval = "1wk"
+	Interval1mo Interval = "1mo"
+
+	// DateTimeFormat is used in place of DateFormat for intraday intervals.
+	DateTimeFormat = "2006-01-02 15:04"
+)
+
+// intervalLimits are the bar duration and the maximum age of the data Yahoo will return
+// for each Interval; a MaxAge of 0 has no limit.
+type intervalLimits struct {
+	Duration time.Duration
+	MaxAge   time.Duration
+}
+
+var (
+	// BarInterval is the Interval requested by NewGroup.
+	BarInterval = Interval1d
+
+	intervals = map[Interval]intervalLimits{
+		Interval1m:  {Duration: time.Minute, MaxAge: 7 * 24 * time.Hour},
+		Interval5m:  {Duration: 5 * time.Minute, MaxAge: 60 * 24 * time.Hour},
+		Interval15m: {Duration: 15 * time.Minute, MaxAge: 60 * 24 * time.Hour},
+		Interval1h:  {Duration: time.Hour, MaxAge: 730 * 24 * time.Hour},
+		Interval1d:  {Duration: 24 * time.Hour},
+		Interval1wk: {Duration: 7 * 24 * time.Hour},
+		Interval1mo: {Duration: 31 * 24 * time.Hour},
+	}
+	// intervalOrder is used to list the intervals in a consistent order.
+	intervalOrder = []Interval{Interval1m, Interval5m, Interval15m, Interval1h, Interval1d, Interval1wk, Interval1mo}
+)
+
+// IntervalKey returns name with the interval appended, for naming files and snapshots; daily
+// data uses name unchanged so data saved before intervals were supported is still found.
+func IntervalKey(name string, interval Interval) string {
+	if interval.normalize() == Interval1d {
+		return name
+	}
+	return name + "-" + string(interval)
+}
+
+// Intervals returns all supported intervals, shortest first.
+func Intervals() []Interval {
+	return append([]Interval(nil), intervalOrder...)
+}
+
+// ParseInterval converts the Yahoo name of an interval (I.E. 5m, 1d) to an Interval.
+func ParseInterval(name string) (Interval, error) {
+	if _, ok := intervals[Interval(name)]; !ok {
+		return Interval1d, fmt.Errorf("invalid interval: %s, valid intervals: %v", name, intervalOrder)
+	}
+	return Interval(name), nil
+}
+
+// Bars returns the bar times of iv in the range [start, end), on the trading days of Calendar;
+// daily and longer bars are at the market open, as are the bars from Yahoo.
+func (iv Interval) Bars(start time.Time, end time.Time) []time.Time {
+	var bars []time.Time
+	var prior time.Time
+	for _, day := range Calendar.TradingDays(start, end) {
+		open := Calendar.MarketOpen(day)
+		switch {
+		case iv.Intraday():
+			for t := open; t.Before(Calendar.MarketClose(day)); t = t.Add(iv.Duration()) {
+				if !t.Before(start) && t.Before(end) {
+					bars = append(bars, t)
+				}
+			}
+			continue
+		case iv == Interval1wk:
+			py, pw := prior.ISOWeek()
+			y, w := day.ISOWeek()
+			if !prior.IsZero() && py == y && pw == w {
+				continue
+			}
+		case iv == Interval1mo:
+			if !prior.IsZero() && prior.Month() == day.Month() {
+				continue
+			}
+		}
+		prior = day
+		if !open.Before(start) && open.Before(end) {
+			bars = append(bars, open)
+		}
+	}
+	return bars
+}
+
+// DateFormat returns the format used to print bar dates; DateTimeFormat for intraday intervals,
+// otherwise DateFormat.
+func (iv Interval) DateFormat() string {
+	if iv.Intraday() {
+		return DateTimeFormat
+	}
+	return DateFormat
+}
+
+// Duration returns the (nominal) time covered by each bar.
+func (iv Interval) Duration() time.Duration {
+	return intervals[iv.normalize()].Duration
+}
+
+// Earliest returns the earliest time for which data can be requested at now; EarliestDate
+// or, for intervals with a maximum age, the later of EarliestDate and now - maximum age.
+func (iv Interval) Earliest(now time.Time) int64 {
+	maxAge := intervals[iv.normalize()].MaxAge
+	if maxAge == 0 {
+		return EarliestDate
+	}
+	// Stay a little inside the limit, as Yahoo rejects requests right at the limit.
+	earliest := now.Add(-maxAge).Add(time.Hour).Unix()
+	if earliest < EarliestDate {
+		return EarliestDate
+	}
+	return earliest
+}
+
+// Intraday returns true for intervals shorter than a day.
+func (iv Interval) Intraday() bool {
+	return iv.Duration() < 24*time.Hour
+}
+
+func (iv Interval) normalize() Interval {
+	if iv == "" {
+		return Interval1d
+	}
+	return iv
+}
@@ -0,0 +1,97 @@
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MissingBarPolicy decides what sources do with a bar for which some prices are missing (null).
+type MissingBarPolicy int
+
+const (
+	// MissingDrop drops the bar.
+	MissingDrop MissingBarPolicy = iota
+	// MissingForwardFill replaces the bar with the close of the prior bar and zero volume. A
+	// missing first bar is dropped.
+	MissingForwardFill
+	// MissingMark keeps the bar with all values set to NaN; Validation then repairs or drops the
+	// bar using the ValidationPolicy. NaN values cannot be saved, so MissingMark requires
+	// PolicyRepair or PolicyDrop; see CheckMissingBars.
+	MissingMark
+)
+
+var (
+	// MissingBars is the MissingBarPolicy used by the sources.
+	MissingBars = MissingDrop
+
+	missingBarPolicyNames = map[MissingBarPolicy]string{MissingDrop: "drop", MissingForwardFill: "forward-fill",
+		MissingMark: "mark"}
+)
+
+// ParseMissingBarPolicy converts the name of a policy (drop, forward-fill, mark) to a MissingBarPolicy.
+func ParseMissingBarPolicy(name string) (MissingBarPolicy, error) {
+	for policy, policyName := range missingBarPolicyNames {
+		if strings.EqualFold(name, policyName) {
+			return policy, nil
+		}
+	}
+	return MissingDrop, fmt.Errorf("invalid missing bar policy: %s", name)
+}
+
+func (mbp MissingBarPolicy) String() string {
+	return missingBarPolicyNames[mbp]
+}
+
+// CheckMissingBars returns an error if policy cannot be used with the validation rules; marked
+// bars must be repaired or dropped by validation, as NaN values cannot be saved as JSON.
+func CheckMissingBars(policy MissingBarPolicy, rules *ValidationRules) error {
+	if policy != MissingMark || (rules != nil && (rules.Policy == PolicyRepair || rules.Policy == PolicyDrop)) {
+		return nil
+	}
+	validation := "off"
+	if rules != nil {
+		validation = rules.Policy.String()
+	}
+	return fmt.Errorf("missing bar policy: %s requires validation policy: %s or %s, validation policy: %s",
+		MissingMark, PolicyRepair, PolicyDrop, validation)
+}
+
+// AppendMissing applies policy to a missing bar at date, and returns true if a bar was appended.
+func (dac *DatasetAsColumns) AppendMissing(date time.Time, policy MissingBarPolicy) bool {
+	var bar Data
+	switch policy {
+	case MissingForwardFill:
+		if len(dac.Date) == 0 {
+			return false
+		}
+		prior := dac.Row(len(dac.Date) - 1)
+		bar = Data{Open: prior.Close, High: prior.Close, Low: prior.Close, Close: prior.Close,
+			AdjOpen: prior.AdjClose, AdjHigh: prior.AdjClose, AdjLow: prior.AdjClose, AdjClose: prior.AdjClose}
+	case MissingMark:
+		nan := math.NaN()
+		bar = Data{Open: nan, High: nan, Low: nan, Close: nan, Volume: nan,
+			AdjOpen: nan, AdjHigh: nan, AdjLow: nan, AdjClose: nan}
+	default:
+		return false
+	}
+	bar.Date = date
+	dac.AppendRow(bar)
+	return true
+}
+
+// AppendRow appends row to dac.
+func (dac *DatasetAsColumns) AppendRow(row Data) {
+	dac.Date = append(dac.Date, row.Date)
+	dac.Open = append(dac.Open, row.Open)
+	dac.High = append(dac.High, row.High)
+	dac.Low = append(dac.Low, row.Low)
+	dac.Close = append(dac.Close, row.Close)
+	dac.Volume = append(dac.Volume, row.Volume)
+	dac.AdjOpen = append(dac.AdjOpen, row.AdjOpen)
+	dac.AdjHigh = append(dac.AdjHigh, row.AdjHigh)
+	dac.AdjLow = append(dac.AdjLow, row.AdjLow)
+	dac.AdjClose = append(dac.AdjClose, row.AdjClose)
+	dac.AdjVolume = append(dac.AdjVolume, row.AdjVolume)
+}
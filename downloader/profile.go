@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-helper/neth/v2/httph"
+)
+
+// Profile is the fundamental data of an Issue: the fund profile of ETFs (expense ratio, AUM,
+// holdings) and the fundamentals of stocks. Zero values are unknown.
+type Profile struct {
+	Name string
+	// QuoteType is the type of the Issue as reported by the source; I.E. ETF, EQUITY.
+	QuoteType string
+	// ExpenseRatio and DividendYield are in percent; I.E. 0.09 is 0.09%.
+	ExpenseRatio  float64
+	DividendYield float64
+	// AUM is the total net assets of a fund, and MarketCap the market capitalization of a stock,
+	// in Currency.
+	AUM       float64
+	MarketCap float64
+	Currency  string
+	// Holdings are the largest holdings of a fund, by Weight descending.
+	Holdings []Holding
+	Sector   string
+	Industry string
+	// TrailingPE, ForwardPE, PriceToBook, and TrailingEPS are for stocks.
+	TrailingPE  float64
+	ForwardPE   float64
+	PriceToBook float64
+	TrailingEPS float64
+	Beta        float64
+	// Updated is the time the Profile was downloaded.
+	Updated time.Time
+}
+
+// Holding is a holding of a fund; Weight is in percent of the fund.
+type Holding struct {
+	Symbol string
+	Name   string
+	Weight float64
+}
+
+// ProfileDownloader is implemented by a Downloader that downloads Profiles.
+type ProfileDownloader interface {
+	NewProfiles(ctx context.Context, liveData bool, dataFilePath string, symbols []string) (map[string]Profile, error)
+}
+
+// URLCollectionDataToProfile converts the data downloaded for one symbol to a Profile.
+type URLCollectionDataToProfile func(ucd httph.URLCollectionData) (Profile, error)
+
+const (
+	ProfileExtension = ".profile.json"
+)
+
+// NewProfiles is a factory for Profiles, keyed by symbol. liveData == true, url (formatted with
+// the symbol) is requested for each symbol and the Profiles are saved; otherwise the Profiles
+// are loaded from the file saved by the prior call. Symbols that fail are logged and have no
+// Profile; an error is only returned when no Profiles were loaded. Downloads report progress
+// and are canceled using ctx, as with NewGroupContext.
+func NewProfiles(ctx context.Context, liveData bool, dataFilePath string, symbols []string, url string,
+	callbackURLCollectionDataToProfile URLCollectionDataToProfile) (map[string]Profile, error) {
+	filePath := dataFilePath + ProfileExtension
+	if !liveData {
+		return LoadProfiles(filePath)
+	}
+
+	urls := make([]string, len(symbols))
+	urlSymbolMap := make(map[string]string)
+	for i, symbol := range symbols {
+		urls[i] = fmt.Sprintf(url, symbol)
+		urlSymbolMap[BaseURL(urls[i])] = symbol
+	}
+	urlData := collectGroup(ctx, urls, urlSymbolMap)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]Profile)
+	var failures []Failure
+	for _, ucd := range urlData {
+		symbol := urlSymbolMap[BaseURL(ucd.URL)]
+		err := ucd.Err
+		if err == nil {
+			var profile Profile
+			if profile, err = callbackURLCollectionDataToProfile(ucd); err == nil {
+				profile.Updated = time.Now()
+				profiles[symbol] = profile
+				continue
+			}
+		}
+		failures = append(failures, Failure{Symbol: symbol, Error: err.Error()})
+	}
+	if len(failures) > 0 {
+		lpf(logh.Warning, "Profiles loaded with failures; failures: %+v", failures)
+	}
+	if len(profiles) == 0 && len(symbols) > 0 {
+		return nil, fmt.Errorf("no profiles loaded, failures: %+v", failures)
+	}
+
+	b, err := json.Marshal(profiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filePath, b, 0644); err != nil {
+		lpf(logh.Error, "saving profiles: %+v", err)
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// LoadProfiles loads the Profiles saved by NewProfiles.
+func LoadProfiles(filePath string) (map[string]Profile, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]Profile)
+	if err := json.Unmarshal(b, &profiles); err != nil {
+		return nil, fmt.Errorf("profiles file: %s, error: %w", filePath, err)
+	}
+	return profiles, nil
+}
+
+// SetProfiles sets the Profile of each Issue in grp that has a Profile in profiles.
+func (grp *Group) SetProfiles(profiles map[string]Profile) {
+	for i := range grp.Issues {
+		if profile, ok := profiles[grp.Issues[i].Symbol]; ok {
+			grp.Issues[i].Profile = &profile
+		}
+	}
+}
+
+// SortByProfile returns the Issues of grp that have a Profile, ordered by the value returned
+// by key, largest first; I.E. key returning -Profile.ExpenseRatio ranks the cheapest funds first.
+func (grp Group) SortByProfile(key func(Profile) float64) []Issue {
+	var issues []Issue
+	for _, iss := range grp.Issues {
+		if iss.Profile != nil {
+			issues = append(issues, iss)
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return key(*issues[i].Profile) > key(*issues[j].Profile) })
+	return issues
+}
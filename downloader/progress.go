@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"context"
+)
+
+// ContextDownloader is implemented by a Downloader whose downloads can be canceled and report
+// progress; see NewGroupFromSource.
+type ContextDownloader interface {
+	NewGroupContext(ctx context.Context, liveData bool, dataFilePath string, name string, symbols []string) (*Group, error)
+}
+
+// ProgressFunc is called as the download of each symbol completes; err is nil on success.
+// Symbols may be reported more than once (I.E. an incremental download that is downloaded
+// again in full), and FX pairs are reported as they are downloaded.
+type ProgressFunc func(symbol string, err error)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that makes the downloads using it call progress.
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// NewGroupFromSource calls NewGroupContext when source is a ContextDownloader; otherwise
+// source.NewGroup is called, and ctx is only checked before the call. The Group is validated
+// using Validation, so every source is validated whether or not it validates the Group itself.
+func NewGroupFromSource(ctx context.Context, source Downloader, liveData bool, dataFilePath string, name string,
+	symbols []string) (*Group, error) {
+	var group *Group
+	var err error
+	if cd, ok := source.(ContextDownloader); ok {
+		group, err = cd.NewGroupContext(ctx, liveData, dataFilePath, name, symbols)
+	} else if err = ctx.Err(); err == nil {
+		group, err = source.NewGroup(liveData, dataFilePath, name, symbols)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGroup(group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// progressFrom returns the ProgressFunc set with WithProgress; nil when there is none.
+func progressFrom(ctx context.Context) ProgressFunc {
+	progress, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return progress
+}
@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+)
+
+// Store keeps timestamped snapshots of each Group, so prior results can be reproduced
+// using the data that was current at the time. Snapshots are saved as gzipped JSON in
+// Directory/<group name>/<SnapshotTimeFormat>.json.gz; names have nanosecond resolution, so
+// snapshots saved in the same second do not replace each other.
+// Groups of bars other than Interval1d are saved using IntervalKey(group name, interval) as the
+// group name.
+type Store struct {
+	Directory string
+	Retention Retention
+}
+
+// Retention is the policy used by Store.Prune to remove old snapshots. The zero value keeps
+// all snapshots.
+type Retention struct {
+	// KeepLast, when > 0, is the maximum number of snapshots kept per group.
+	KeepLast int
+	// MaxAge, when > 0, removes snapshots older than MaxAge. The newest snapshot is always kept.
+	MaxAge time.Duration
+}
+
+// Snapshot identifies a single saved Group.
+type Snapshot struct {
+	Group    string
+	Time     time.Time
+	FilePath string
+}
+
+const (
+	SnapshotExtension  = GroupExtension + ".gz"
+	SnapshotTimeFormat = "20060102T150405.000000000Z"
+
+	// snapshotParseFormat parses snapshot names with or without fractional seconds.
+	snapshotParseFormat = "20060102T150405Z"
+)
+
+var (
+	// SnapshotStore, when not nil, is used by NewGroup to save a snapshot of every Group that is
+	// downloaded, then prune old snapshots.
+	SnapshotStore *Store
+)
+
+// NewStore is a factory for Store; directory is created if it does not exist.
+func NewStore(directory string, retention Retention) (*Store, error) {
+	if err := os.MkdirAll(directory, 0777); err != nil {
+		lpf(logh.Error, "creating snapshot directory: %+v", err)
+		return nil, err
+	}
+	return &Store{Directory: directory, Retention: retention}, nil
+}
+
+// List returns all snapshots for the group, in Time ascending order.
+func (st Store) List(groupName string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(st.Directory, groupName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		lpf(logh.Error, "reading snapshot directory: %+v", err)
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, SnapshotExtension) {
+			continue
+		}
+		t, err := time.Parse(snapshotParseFormat, strings.TrimSuffix(name, SnapshotExtension))
+		if err != nil {
+			lpf(logh.Warning, "skipping file with invalid snapshot name: %s", name)
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Group: groupName, Time: t,
+			FilePath: filepath.Join(st.Directory, groupName, name)})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// Load returns the Group saved in snapshot.
+func (st Store) Load(snapshot Snapshot) (*Group, error) {
+	f, err := os.Open(snapshot.FilePath)
+	if err != nil {
+		lpf(logh.Error, "opening snapshot: %+v", err)
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		lpf(logh.Error, "reading snapshot: %+v", err)
+		return nil, err
+	}
+	defer zr.Close()
+
+	group := new(Group)
+	if err := json.NewDecoder(zr).Decode(group); err != nil {
+		lpf(logh.Error, "unmarshaling snapshot: %+v", err)
+		return nil, err
+	}
+	return group, nil
+}
+
+// LoadAsOf returns the Group in the newest snapshot saved at or before asOf.
+func (st Store) LoadAsOf(groupName string, asOf time.Time) (*Group, Snapshot, error) {
+	snapshots, err := st.List(groupName)
+	if err != nil {
+		return nil, Snapshot{}, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Time.After(asOf) {
+			continue
+		}
+		group, err := st.Load(snapshots[i])
+		return group, snapshots[i], err
+	}
+	return nil, Snapshot{}, fmt.Errorf("no snapshot for group: %s at or before: %s", groupName, asOf.Format(time.RFC3339))
+}
+
+// Prune removes the snapshots for the group that are not kept by the Retention policy, and
+// returns the removed snapshots.
+func (st Store) Prune(groupName string) ([]Snapshot, error) {
+	return st.pruneAt(groupName, time.Now())
+}
+
+// Save saves a snapshot of group, named using the current time.
+func (st Store) Save(group *Group) (Snapshot, error) {
+	return st.saveAt(group, time.Now())
+}
+
+func (st Store) pruneAt(groupName string, now time.Time) ([]Snapshot, error) {
+	snapshots, err := st.List(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Snapshot
+	for i, snapshot := range snapshots {
+		newerCount := len(snapshots) - 1 - i
+		tooMany := st.Retention.KeepLast > 0 && newerCount >= st.Retention.KeepLast
+		tooOld := st.Retention.MaxAge > 0 && newerCount > 0 && now.Sub(snapshot.Time) > st.Retention.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(snapshot.FilePath); err != nil {
+			lpf(logh.Error, "removing snapshot: %+v", err)
+			return removed, err
+		}
+		removed = append(removed, snapshot)
+	}
+	return removed, nil
+}
+
+// saveAt saves a snapshot of group named using t. A snapshot that could not be written
+// completely is removed, so LoadAsOf never finds a partial snapshot.
+func (st Store) saveAt(group *Group, t time.Time) (Snapshot, error) {
+	groupName := IntervalKey(group.Name, group.Interval())
+	directory := filepath.Join(st.Directory, groupName)
+	if err := os.MkdirAll(directory, 0777); err != nil {
+		lpf(logh.Error, "creating snapshot directory: %+v", err)
+		return Snapshot{}, err
+	}
+	t = t.UTC()
+	snapshot := Snapshot{Group: groupName, Time: t,
+		FilePath: filepath.Join(directory, t.Format(SnapshotTimeFormat)+SnapshotExtension)}
+
+	f, err := os.Create(snapshot.FilePath)
+	if err != nil {
+		lpf(logh.Error, "creating snapshot: %+v", err)
+		return Snapshot{}, err
+	}
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(group)
+	if err == nil {
+		err = zw.Close()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		lpf(logh.Error, "writing snapshot: %+v", err)
+		if errRemove := os.Remove(snapshot.FilePath); errRemove != nil {
+			lpf(logh.Error, "removing partial snapshot: %+v", errRemove)
+		}
+		return Snapshot{}, err
+	}
+	lpf(logh.Info, "Snapshot saved; group: %s, file: %s", groupName, snapshot.FilePath)
+	return snapshot, nil
+}
@@ -0,0 +1,424 @@
+// Package synthetic implements a price source that generates Groups from models of returns,
+// so strategies can be tested in conditions that are not in the price history, and tests have
+// large, reproducible inputs. The models are geometric Brownian motion, regime switching, jump
+// diffusion, and bootstrapped resampling of real returns.
+//
+// Generation is deterministic: the same Options and symbol always generate the same bars.
+// Each symbol has its own random source, seeded from Options.Seed and the symbol, so adding a
+// symbol does not change the others, and a later End extends the bars of an earlier End.
+package synthetic
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-helper/mathh/v2"
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/keyValue"
+)
+
+// Model is a model of returns.
+type Model string
+
+// Options control the generated data.
+type Options struct {
+	Model Model
+	Seed  int64
+	// Bars of dl.BarInterval are generated on the trading days of dl.Calendar in [Start, End);
+	// End is now when zero.
+	Start time.Time
+	End   time.Time
+	// StartPrice is the first Open of every symbol.
+	StartPrice float64
+	// Drift and Volatility are annualized; I.E. 0.07 and 0.16. Used by GBM and JumpDiffusion.
+	Drift      float64
+	Volatility float64
+	// Regimes are the states of RegimeSwitching, which starts in the first Regime.
+	Regimes []Regime
+	// JumpsPerYear is the expected number of jumps; the log of each jump is normal with mean
+	// JumpMean and standard deviation JumpStdDev. I.E. -0.05 and 0.1 for crashes.
+	JumpsPerYear float64
+	JumpMean     float64
+	JumpStdDev   float64
+	// Returns are the bar to bar returns (I.E. AdjClose[i]/AdjClose[i-1]) resampled by Bootstrap;
+	// see Returns. When empty, the returns of ReturnsFrom are used.
+	Returns []float64
+	// ReturnsFrom is the dataFilePath of a Group saved by a prior download; the returns of the
+	// Issue with the generated symbol are resampled, or of the first Issue when there is none.
+	ReturnsFrom string
+	// BlockSize is the number of consecutive returns in each resampled block, which keeps the
+	// short term autocorrelation (I.E. volatility clustering) of the returns; 1 when < 1.
+	BlockSize int
+}
+
+// Regime is a state of RegimeSwitching. The number of bars in a regime is geometric with a
+// mean of MeanBars; the next regime is chosen at random from the other regimes.
+type Regime struct {
+	Drift      float64
+	Volatility float64
+	MeanBars   int
+}
+
+// Source implements dl.Downloader and is registered with the downloader as SourceName. The
+// Options of a Source with no Model are Defaults.
+type Source struct {
+	Options Options
+}
+
+const (
+	// SourceName is the name used to register this source with the downloader.
+	SourceName = "synthetic"
+
+	GBM             Model = "gbm"
+	RegimeSwitching Model = "regime"
+	JumpDiffusion   Model = "jump"
+	Bootstrap       Model = "bootstrap"
+
+	// tradingDaysPerYear converts annualized Drift and Volatility to bars.
+	tradingDaysPerYear = 252
+)
+
+var (
+	appName string
+	// lp      func(level logh.LoghLevel, v ...interface{})
+	lpf func(level logh.LoghLevel, format string, v ...interface{})
+
+	// Defaults are the Options of the registered Source; see ParseOptions.
+	Defaults = Options{
+		Model:      GBM,
+		Seed:       1,
+		Start:      time.Unix(dl.EarliestDate, 0).UTC(),
+		StartPrice: 100,
+		Drift:      0.07,
+		Volatility: 0.16,
+		Regimes: []Regime{
+			{Drift: 0.12, Volatility: 0.12, MeanBars: 750},
+			{Drift: -0.25, Volatility: 0.35, MeanBars: 120},
+		},
+		JumpsPerYear: 1,
+		JumpMean:     -0.05,
+		JumpStdDev:   0.1,
+		BlockSize:    20,
+	}
+
+	models = []Model{GBM, RegimeSwitching, JumpDiffusion, Bootstrap}
+)
+
+func Init(appNameInit string) {
+	appName = appNameInit
+	// lp = logh.Map[appName].Println
+	lpf = logh.Map[appName].Printf
+
+	if err := dl.Register(SourceName, Source{}); err != nil {
+		lpf(logh.Warning, "registering source: %+v", err)
+	}
+}
+
+// NewGroup implements dl.Downloader. The data is always generated; liveData and dataFilePath
+// are ignored, and nothing is saved.
+func (src Source) NewGroup(liveData bool, dataFilePath string, name string, symbols []string) (*dl.Group, error) {
+	opts := src.Options
+	if opts.Model == "" {
+		opts = Defaults
+	}
+	if opts.End.IsZero() {
+		opts.End = time.Now()
+	}
+	bars := dl.BarInterval.Bars(opts.Start, opts.End)
+	if len(bars) < 2 {
+		return nil, fmt.Errorf("no bars of interval: %s from: %s to: %s", dl.BarInterval, opts.Start, opts.End)
+	}
+
+	var returnsGroup *dl.Group
+	if opts.Model == Bootstrap && len(opts.Returns) == 0 {
+		if opts.ReturnsFrom == "" {
+			return nil, fmt.Errorf("model: %s requires Returns or ReturnsFrom", Bootstrap)
+		}
+		var err error
+		if returnsGroup, err = dl.LoadGroupFromFile(dl.IntervalKey(opts.ReturnsFrom, dl.BarInterval)); err != nil {
+			return nil, err
+		}
+	}
+
+	group := &dl.Group{Name: name}
+	for _, symbol := range symbols {
+		symbolOpts := opts
+		if returnsGroup != nil {
+			symbolOpts.Returns = groupReturns(returnsGroup, symbol)
+		}
+		issue, err := symbolOpts.issue(symbol, bars)
+		if err != nil {
+			lpf(logh.Error, "%+v", err)
+			return nil, err
+		}
+		group.Issues = append(group.Issues, issue)
+	}
+	lpf(logh.Info, "Group generated; model: %s, seed: %d, bars: %d, symbols: %v", opts.Model, opts.Seed, len(bars), symbols)
+	return group, nil
+}
+
+// ParseOptions returns base changed by spec, a comma separated list of key=value pairs; I.E.
+// "model=jump,seed=7,volatility=0.2". The keys are model, seed, start and end (YYYY-MM-DD),
+// startPrice, drift, volatility, jumpsPerYear, jumpMean, jumpStdDev, returnsFrom, blockSize,
+// and regimes (drift:volatility:meanBars for each regime, separated by /).
+func ParseOptions(spec string, base Options) (Options, error) {
+	opts := base
+	err := keyValue.Parse(spec, func(key string, value string) error {
+		var err error
+		switch key {
+		case "model":
+			opts.Model, err = ParseModel(value)
+		case "seed":
+			opts.Seed, err = strconv.ParseInt(value, 10, 64)
+		case "start":
+			opts.Start, err = time.ParseInLocation(dl.DateFormat, value, dl.Calendar.Location)
+		case "end":
+			opts.End, err = time.ParseInLocation(dl.DateFormat, value, dl.Calendar.Location)
+		case "startprice":
+			opts.StartPrice, err = strconv.ParseFloat(value, 64)
+		case "drift":
+			opts.Drift, err = strconv.ParseFloat(value, 64)
+		case "volatility":
+			opts.Volatility, err = strconv.ParseFloat(value, 64)
+		case "jumpsperyear":
+			opts.JumpsPerYear, err = strconv.ParseFloat(value, 64)
+		case "jumpmean":
+			opts.JumpMean, err = strconv.ParseFloat(value, 64)
+		case "jumpstddev":
+			opts.JumpStdDev, err = strconv.ParseFloat(value, 64)
+		case "returnsfrom":
+			opts.ReturnsFrom = value
+		case "blocksize":
+			opts.BlockSize, err = strconv.Atoi(value)
+		case "regimes":
+			opts.Regimes, err = parseRegimes(value)
+		default:
+			return fmt.Errorf("unknown key")
+		}
+		return err
+	})
+	if err != nil {
+		return base, fmt.Errorf("invalid option: %w", err)
+	}
+	return opts, nil
+}
+
+// ParseModel converts the name of a Model.
+func ParseModel(name string) (Model, error) {
+	for _, model := range models {
+		if strings.EqualFold(string(model), strings.TrimSpace(name)) {
+			return model, nil
+		}
+	}
+	return "", fmt.Errorf("invalid model: %s, valid models: %v", name, models)
+}
+
+// Returns returns the bar to bar returns of AdjClose of iss, for use as Options.Returns.
+func Returns(iss dl.Issue) []float64 {
+	adjClose := iss.DatasetAsColumns.AdjClose
+	var returns []float64
+	for i := 1; i < len(adjClose); i++ {
+		if adjClose[i-1] > 0 && adjClose[i] > 0 {
+			returns = append(returns, adjClose[i]/adjClose[i-1])
+		}
+	}
+	return returns
+}
+
+// barsPerYear returns the number of bars of interval in a year of trading.
+func barsPerYear(interval dl.Interval) float64 {
+	switch {
+	case interval.Intraday():
+		return tradingDaysPerYear * (6.5 * float64(time.Hour)) / float64(interval.Duration())
+	case interval == dl.Interval1wk:
+		return 52
+	case interval == dl.Interval1mo:
+		return 12
+	}
+	return tradingDaysPerYear
+}
+
+// groupReturns returns the returns of the Issue for symbol in group, or of the first Issue.
+func groupReturns(group *dl.Group, symbol string) []float64 {
+	for _, iss := range group.Issues {
+		if iss.Symbol == symbol {
+			return Returns(iss)
+		}
+	}
+	if len(group.Issues) == 0 {
+		return nil
+	}
+	return Returns(group.Issues[0])
+}
+
+// issue generates the Issue for symbol with a bar at each time in bars.
+func (opts Options) issue(symbol string, bars []time.Time) (dl.Issue, error) {
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	seed := opts.Seed ^ int64(h.Sum64())
+	// The returns and the intrabar values have separate sources, so both are the same for the
+	// bars in common when End changes.
+	logReturns, err := opts.logReturns(rand.New(rand.NewSource(seed)), len(bars))
+	if err != nil {
+		return dl.Issue{}, fmt.Errorf("symbol: %s, %w", symbol, err)
+	}
+
+	// The intrabar range is scaled from the bar volatility of the model.
+	sigma := opts.barVolatility()
+	rnd := rand.New(rand.NewSource(^seed))
+	startPrice := opts.StartPrice
+	if startPrice <= 0 {
+		startPrice = Defaults.StartPrice
+	}
+	issue := dl.Issue{Symbol: symbol, URL: SourceName, Interval: dl.BarInterval,
+		Meta: dl.Meta{Currency: "USD", ExchangeName: "SYNTHETIC", InstrumentType: string(opts.Model),
+			Timezone: dl.Calendar.Location.String(), FirstTradeDate: bars[0]}}
+	dac := &issue.DatasetAsColumns
+	open := startPrice
+	for i, bar := range bars {
+		close := open * math.Exp(logReturns[i])
+		high := math.Max(open, close) * math.Exp(0.5*sigma*math.Abs(rnd.NormFloat64()))
+		low := math.Min(open, close) * math.Exp(-0.5*sigma*math.Abs(rnd.NormFloat64()))
+		volume := math.Round(1e6 * math.Exp(0.3*rnd.NormFloat64()))
+		prices := []float64{open, high, low, close}
+		for j := range prices {
+			prices[j] = math.Max(mathh.Round(prices[j], dl.InputPrecision), math.Pow(10, -dl.InputPrecision))
+		}
+		dac.Date = append(dac.Date, bar)
+		dac.Open = append(dac.Open, prices[0])
+		dac.High = append(dac.High, prices[1])
+		dac.Low = append(dac.Low, prices[2])
+		dac.Close = append(dac.Close, prices[3])
+		dac.Volume = append(dac.Volume, volume)
+		dac.AdjOpen = append(dac.AdjOpen, prices[0])
+		dac.AdjHigh = append(dac.AdjHigh, prices[1])
+		dac.AdjLow = append(dac.AdjLow, prices[2])
+		dac.AdjClose = append(dac.AdjClose, prices[3])
+		dac.AdjVolume = append(dac.AdjVolume, volume)
+		// The next bar opens at this close.
+		open = close
+	}
+	issue.Meta.RegularMarketPrice = dac.Close[len(dac.Close)-1]
+	return issue, nil
+}
+
+// barVolatility returns the standard deviation of the bar log returns of the Model; for
+// RegimeSwitching, the mean of the regimes.
+func (opts Options) barVolatility() float64 {
+	dt := 1 / barsPerYear(dl.BarInterval)
+	switch opts.Model {
+	case RegimeSwitching:
+		var sum float64
+		for _, r := range opts.Regimes {
+			sum += r.Volatility
+		}
+		return sum / float64(len(opts.Regimes)) * math.Sqrt(dt)
+	case Bootstrap:
+		logReturns := make([]float64, len(opts.Returns))
+		for i, r := range opts.Returns {
+			logReturns[i] = math.Log(r)
+		}
+		return stdDev(logReturns)
+	}
+	return opts.Volatility * math.Sqrt(dt)
+}
+
+// logReturns returns n bar log returns (open to close) generated by the Model.
+func (opts Options) logReturns(rnd *rand.Rand, n int) ([]float64, error) {
+	dt := 1 / barsPerYear(dl.BarInterval)
+	gbm := func(drift, volatility float64) float64 {
+		return (drift-volatility*volatility/2)*dt + volatility*math.Sqrt(dt)*rnd.NormFloat64()
+	}
+
+	logReturns := make([]float64, n)
+	switch opts.Model {
+	case GBM:
+		for i := range logReturns {
+			logReturns[i] = gbm(opts.Drift, opts.Volatility)
+		}
+	case RegimeSwitching:
+		if len(opts.Regimes) == 0 {
+			return nil, fmt.Errorf("model: %s requires Regimes", RegimeSwitching)
+		}
+		regime := 0
+		for i := range logReturns {
+			r := opts.Regimes[regime]
+			logReturns[i] = gbm(r.Drift, r.Volatility)
+			if len(opts.Regimes) > 1 && r.MeanBars > 0 && rnd.Float64() < 1/float64(r.MeanBars) {
+				regime = (regime + 1 + rnd.Intn(len(opts.Regimes)-1)) % len(opts.Regimes)
+			}
+		}
+	case JumpDiffusion:
+		for i := range logReturns {
+			logReturns[i] = gbm(opts.Drift, opts.Volatility)
+			for jumps := poisson(rnd, opts.JumpsPerYear*dt); jumps > 0; jumps-- {
+				logReturns[i] += opts.JumpMean + opts.JumpStdDev*rnd.NormFloat64()
+			}
+		}
+	case Bootstrap:
+		if len(opts.Returns) == 0 {
+			return nil, fmt.Errorf("model: %s requires Returns or ReturnsFrom", Bootstrap)
+		}
+		blockSize := opts.BlockSize
+		if blockSize < 1 {
+			blockSize = 1
+		}
+		for i := 0; i < n; {
+			start := rnd.Intn(len(opts.Returns))
+			for j := 0; j < blockSize && i < n; j, i = j+1, i+1 {
+				logReturns[i] = math.Log(opts.Returns[(start+j)%len(opts.Returns)])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid model: %s, valid models: %v", opts.Model, models)
+	}
+	return logReturns, nil
+}
+
+// parseRegimes parses drift:volatility:meanBars for each regime, separated by /.
+func parseRegimes(value string) ([]Regime, error) {
+	var regimes []Regime
+	for _, spec := range strings.Split(value, "/") {
+		fields := strings.Split(spec, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("regime: %s, expected drift:volatility:meanBars", spec)
+		}
+		var r Regime
+		var err1, err2, err3 error
+		r.Drift, err1 = strconv.ParseFloat(fields[0], 64)
+		r.Volatility, err2 = strconv.ParseFloat(fields[1], 64)
+		r.MeanBars, err3 = strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("regime: %s, expected drift:volatility:meanBars", spec)
+		}
+		regimes = append(regimes, r)
+	}
+	return regimes, nil
+}
+
+// poisson returns a Poisson distributed count with mean lambda (Knuth).
+func poisson(rnd *rand.Rand, lambda float64) int {
+	limit := math.Exp(-lambda)
+	k := 0
+	for p := rnd.Float64(); p > limit; p *= rnd.Float64() {
+		k++
+	}
+	return k
+}
+
+// stdDev returns the standard deviation of values.
+func stdDev(values []float64) float64 {
+	var sum, sumSq float64
+	for _, v := range values {
+		sum += v
+		sumSq += v * v
+	}
+	n := float64(len(values))
+	return math.Sqrt(math.Max(sumSq/n-(sum/n)*(sum/n), 0))
+}
@@ -0,0 +1,67 @@
+package synthetic
+
+import (
+	"fmt"
+	"time"
+
+	dl "github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+func init() {
+	Init("test")
+	dl.Init("test")
+}
+
+func ExampleSource_NewGroup() {
+	start := time.Date(2022, time.January, 1, 0, 0, 0, 0, dl.Calendar.Location)
+	opts := Defaults
+	opts.Start, opts.End = start, start.AddDate(0, 0, 10)
+	returns := []float64{1.01, 0.99, 1.02, 0.98}
+	for _, model := range models {
+		opts.Model = model
+		opts.Returns = returns
+		group, err := Source{Options: opts}.NewGroup(true, "", "synthetic", []string{"aaa", "bbb"})
+		if err != nil {
+			fmt.Printf("%+v\n", err)
+			return
+		}
+		dac := group.Issues[0].DatasetAsColumns
+		fmt.Printf("%-9s %s %d %+v\n", model, dac.Date[0].Format(dl.DateFormat), len(dac.Date), dac.Close)
+	}
+
+	// The same Options always generate the same bars, and a later End extends them.
+	opts.Model = GBM
+	first, _ := Source{Options: opts}.NewGroup(true, "", "synthetic", []string{"aaa"})
+	opts.End = opts.End.AddDate(0, 0, 5)
+	longer, _ := Source{Options: opts}.NewGroup(true, "", "synthetic", []string{"aaa", "bbb"})
+	fmt.Println(first.Issues[0].DatasetAsColumns.High, longer.Issues[0].DatasetAsColumns.High[:6])
+
+	opts.Model = Bootstrap
+	opts.Returns = nil
+	_, err := Source{Options: opts}.NewGroup(true, "", "synthetic", []string{"aaa"})
+	fmt.Println(err)
+
+	// Output:
+	// gbm       2022-01-03 6 [101.98 102.68 102.47 102.93 104.35 105.86]
+	// regime    2022-01-03 6 [101.51 101.38 102.46 101.34 102.01 100.87]
+	// jump      2022-01-03 6 [101.98 101.77 103.17 101.64 102.5 100.94]
+	// bootstrap 2022-01-03 6 [98 98.98 97.99 99.95 97.95 98.93]
+	// [102.02 103.1 102.75 103.76 104.7 106] [102.02 103.1 102.75 103.76 104.7 106]
+	// model: bootstrap requires Returns or ReturnsFrom
+}
+
+func ExampleParseOptions() {
+	opts, err := ParseOptions("model=regime, seed=7, start=2000-01-03, regimes=0.1:0.1:500/-0.3:0.4:60", Defaults)
+	fmt.Println(opts.Model, opts.Seed, opts.Start.Format(dl.DateFormat), opts.Regimes, err)
+	for _, spec := range []string{"model=garch", "seed", "regimes=0.1:0.1", "color=red"} {
+		_, err = ParseOptions(spec, Defaults)
+		fmt.Println(err)
+	}
+
+	// Output:
+	// regime 7 2000-01-03 [{0.1 0.1 500} {-0.3 0.4 60}] <nil>
+	// invalid option: model=garch, error: invalid model: garch, valid models: [gbm regime jump bootstrap]
+	// invalid option: seed, expected key=value
+	// invalid option: regimes=0.1:0.1, error: regime: 0.1:0.1, expected drift:volatility:meanBars
+	// invalid option: color=red, error: unknown key
+}
@@ -0,0 +1,391 @@
+package downloader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-quantstudio/calendar"
+)
+
+// ValidationPolicy decides what Validate does with bars that fail a check.
+type ValidationPolicy int
+
+// ValidationRules configure Validate.
+type ValidationRules struct {
+	Policy ValidationPolicy
+	// SpikeSigma is the number of standard deviations of the bar to bar AdjClose log returns above
+	// which a return is flagged as a spike; 0 disables the check.
+	SpikeSigma float64
+}
+
+// Check identifies a single data quality check.
+type Check string
+
+// Finding is a single bar that failed a Check, and the Action that was taken.
+type Finding struct {
+	Check  Check
+	Date   time.Time
+	Detail string
+	Action string
+}
+
+// IssueReport holds all findings for a single Issue.
+type IssueReport struct {
+	Symbol   string
+	Bars     int
+	Findings []Finding
+}
+
+const (
+	// PolicyReport only reports findings.
+	PolicyReport ValidationPolicy = iota
+	// PolicyRepair repairs bars where possible; see Validate.
+	PolicyRepair
+	// PolicyDrop drops bars.
+	PolicyDrop
+	// PolicyFail returns an error.
+	PolicyFail
+)
+
+const (
+	CheckNaN         Check = "NaN or null value"
+	CheckNonPositive Check = "zero or negative price"
+	CheckDuplicate   Check = "duplicate timestamp"
+	CheckHighLow     Check = "high < low"
+	CheckCloseRange  Check = "close outside [low, high]"
+	CheckSpike       Check = "price spike"
+	CheckZeroVolume  Check = "zero volume"
+	CheckMissingDays Check = "missing trading days"
+
+	ActionReported = "reported"
+	ActionRepaired = "repaired"
+	ActionDropped  = "dropped"
+)
+
+var (
+	// Calendar is the trading calendar used to find missing trading days.
+	Calendar = calendar.NYSE
+
+	// Validation, when not nil, is used by NewGroup to validate every Group.
+	Validation *ValidationRules
+
+	// reportOnlyChecks are never repaired or dropped, as they are expected in valid data;
+	// I.E. indices such as ^tnx have no volume.
+	reportOnlyChecks = []Check{CheckZeroVolume, CheckMissingDays}
+
+	validationPolicyNames = map[ValidationPolicy]string{PolicyReport: "report", PolicyRepair: "repair",
+		PolicyDrop: "drop", PolicyFail: "fail"}
+)
+
+// ParseValidationPolicy converts the name of a policy (report, repair, drop, fail) to a ValidationPolicy.
+func ParseValidationPolicy(name string) (ValidationPolicy, error) {
+	for policy, policyName := range validationPolicyNames {
+		if strings.EqualFold(name, policyName) {
+			return policy, nil
+		}
+	}
+	return PolicyReport, fmt.Errorf("invalid validation policy: %s", name)
+}
+
+func (vp ValidationPolicy) String() string {
+	return validationPolicyNames[vp]
+}
+
+// Counts returns the number of findings for each Check.
+func (ir IssueReport) Counts() map[Check]int {
+	counts := make(map[Check]int)
+	for _, f := range ir.Findings {
+		counts[f.Check]++
+	}
+	return counts
+}
+
+func (ir IssueReport) String() string {
+	counts := ir.Counts()
+	checks := make([]string, 0, len(counts))
+	for check := range counts {
+		checks = append(checks, string(check))
+	}
+	sort.Strings(checks)
+	out := fmt.Sprintf("symbol:%5s, bars:%d, findings:%d", ir.Symbol, ir.Bars, len(ir.Findings))
+	for _, check := range checks {
+		out += fmt.Sprintf(", %s:%d", check, counts[Check(check)])
+	}
+	return out
+}
+
+// Validate checks every Issue in the group and applies rules.Policy to the bars that fail a
+// check. Reports are returned for all issues; with PolicyFail an error is also returned if any
+// bar failed a check that can be repaired or dropped.
+func (grp *Group) Validate(rules ValidationRules) ([]IssueReport, error) {
+	reports := make([]IssueReport, 0, len(grp.Issues))
+	var failed []string
+	for i := range grp.Issues {
+		report := grp.Issues[i].Validate(rules)
+		reports = append(reports, report)
+		if len(report.Findings) > 0 {
+			lpf(logh.Warning, "Validation; %s", report)
+		}
+		for _, f := range report.Findings {
+			if !isReportOnly(f.Check) && rules.Policy == PolicyFail {
+				failed = append(failed, grp.Issues[i].Symbol)
+				break
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		err := fmt.Errorf("validation failed for symbols: %v", failed)
+		lpf(logh.Error, "%+v", err)
+		return reports, err
+	}
+	return reports, nil
+}
+
+// Validate checks the Issue for NaN values, zero or negative prices, duplicate timestamps,
+// high < low, close outside of [low, high], spikes, zero volume, and missing trading days, then
+// applies rules.Policy.
+// PolicyRepair: NaN, zero, and negative prices are replaced with the prior close (the first bar
+// has no prior close, so it is dropped), the first of duplicate bars is dropped, high and low
+// are swapped or extended to include open and close, and spikes are replaced by the prior bar.
+// Only spikes that revert on the next bar are repaired or dropped; a spike that does not revert
+// is a real price move (I.E. a crash) and is only reported. Zero volume and missing trading days
+// are always only reported.
+func (iss *Issue) Validate(rules ValidationRules) IssueReport {
+	rows := iss.DatasetAsColumns.ToDataset()
+	report := IssueReport{Symbol: iss.Symbol, Bars: len(rows)}
+	action := ActionReported
+	switch rules.Policy {
+	case PolicyRepair:
+		action = ActionRepaired
+	case PolicyDrop:
+		action = ActionDropped
+	}
+	find := func(check Check, date time.Time, detail string, reportOnly bool) string {
+		a := action
+		if reportOnly || isReportOnly(check) || rules.Policy == PolicyFail {
+			a = ActionReported
+		}
+		report.Findings = append(report.Findings, Finding{Check: check, Date: date, Detail: detail, Action: a})
+		return a
+	}
+
+	out := make([]Data, 0, len(rows))
+	for i, row := range rows {
+		if i < len(rows)-1 && rows[i+1].Date.Equal(row.Date) {
+			if find(CheckDuplicate, row.Date, "", false) != ActionReported {
+				continue
+			}
+		}
+		if hasNaN(row) {
+			switch find(CheckNaN, row.Date, fmt.Sprintf("%+v", row), false) {
+			case ActionDropped:
+				continue
+			case ActionRepaired:
+				if len(out) == 0 {
+					// There is no prior close to repair the first bar, so it is dropped.
+					report.Findings[len(report.Findings)-1].Action = ActionDropped
+					continue
+				}
+				row = repairPrices(row, out[len(out)-1], invalid)
+			}
+		}
+		if hasNonPositive(row) {
+			switch find(CheckNonPositive, row.Date, fmt.Sprintf("%+v", row), false) {
+			case ActionDropped:
+				continue
+			case ActionRepaired:
+				if len(out) == 0 {
+					report.Findings[len(report.Findings)-1].Action = ActionDropped
+					continue
+				}
+				row = repairPrices(row, out[len(out)-1], nonPositive)
+			}
+		}
+		if row.High < row.Low {
+			switch find(CheckHighLow, row.Date, fmt.Sprintf("high: %f, low: %f", row.High, row.Low), false) {
+			case ActionDropped:
+				continue
+			case ActionRepaired:
+				row.High, row.Low = row.Low, row.High
+				row.AdjHigh, row.AdjLow = row.AdjLow, row.AdjHigh
+			}
+		}
+		if row.Close < row.Low || row.Close > row.High {
+			switch find(CheckCloseRange, row.Date, fmt.Sprintf("close: %f, low: %f, high: %f", row.Close, row.Low, row.High), false) {
+			case ActionDropped:
+				continue
+			case ActionRepaired:
+				row = repairRange(row)
+			}
+		}
+		if row.Volume == 0 {
+			find(CheckZeroVolume, row.Date, "", false)
+		}
+		out = append(out, row)
+	}
+
+	if rules.SpikeSigma > 0 {
+		out = validateSpikes(out, rules.SpikeSigma, find)
+	}
+	// Bars longer than a day always span trading days.
+	for i := 1; i < len(out) && iss.Interval.Duration() <= 24*time.Hour; i++ {
+		if missing := Calendar.MissingTradingDays(out[i-1].Date, out[i].Date); len(missing) > 0 {
+			find(CheckMissingDays, out[i].Date, fmt.Sprintf("missing: %d, after: %s", len(missing), out[i-1].Date.Format(iss.Interval.DateFormat())), false)
+		}
+	}
+
+	if action != ActionReported && rules.Policy != PolicyFail {
+		iss.DatasetAsColumns = Issue{Dataset: out}.ToDatasetAsColumns()
+	}
+	return report
+}
+
+// Row returns row i of dac.
+func (dac DatasetAsColumns) Row(i int) Data {
+	return Data{Date: dac.Date[i], Open: dac.Open[i], High: dac.High[i], Low: dac.Low[i], Close: dac.Close[i],
+		Volume: dac.Volume[i], AdjOpen: dac.AdjOpen[i], AdjHigh: dac.AdjHigh[i], AdjLow: dac.AdjLow[i],
+		AdjClose: dac.AdjClose[i], AdjVolume: dac.AdjVolume[i]}
+}
+
+// ToDataset converts dac to row format; the inverse of Issue.ToDatasetAsColumns.
+func (dac DatasetAsColumns) ToDataset() []Data {
+	out := make([]Data, len(dac.Date))
+	for i := range dac.Date {
+		out[i] = dac.Row(i)
+	}
+	return out
+}
+
+func hasNaN(row Data) bool {
+	for _, v := range []float64{row.Open, row.High, row.Low, row.Close, row.Volume,
+		row.AdjOpen, row.AdjHigh, row.AdjLow, row.AdjClose} {
+		if invalid(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNonPositive returns true when a price of row is zero or negative; volume may be zero.
+func hasNonPositive(row Data) bool {
+	for _, v := range []float64{row.Open, row.High, row.Low, row.Close, row.AdjOpen, row.AdjHigh, row.AdjLow, row.AdjClose} {
+		if nonPositive(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalid returns true for NaN and Inf values.
+func invalid(v float64) bool {
+	return math.IsNaN(v) || math.IsInf(v, 0)
+}
+
+// nonPositive returns true for zero and negative values.
+func nonPositive(v float64) bool {
+	return v <= 0
+}
+
+func isReportOnly(check Check) bool {
+	for _, c := range reportOnlyChecks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+// repairPrices replaces the values of row for which bad returns true with the close of prior;
+// volume is replaced with 0.
+func repairPrices(row Data, prior Data, bad func(v float64) bool) Data {
+	fix := func(v *float64, replacement float64) {
+		if bad(*v) {
+			*v = replacement
+		}
+	}
+	fix(&row.Open, prior.Close)
+	fix(&row.High, prior.Close)
+	fix(&row.Low, prior.Close)
+	fix(&row.Close, prior.Close)
+	fix(&row.Volume, 0)
+	fix(&row.AdjOpen, prior.AdjClose)
+	fix(&row.AdjHigh, prior.AdjClose)
+	fix(&row.AdjLow, prior.AdjClose)
+	fix(&row.AdjClose, prior.AdjClose)
+	return row
+}
+
+// repairRange extends high and low to include open and close.
+func repairRange(row Data) Data {
+	row.High = math.Max(row.High, math.Max(row.Open, row.Close))
+	row.Low = math.Min(row.Low, math.Min(row.Open, row.Close))
+	row.AdjHigh = math.Max(row.AdjHigh, math.Max(row.AdjOpen, row.AdjClose))
+	row.AdjLow = math.Min(row.AdjLow, math.Min(row.AdjOpen, row.AdjClose))
+	return row
+}
+
+// validateSpikes flags AdjClose log returns more than sigma standard deviations from the mean.
+// Spikes are only repaired or dropped when the next return reverts the spike. Returns of bars
+// without a positive AdjClose (I.E. reported by PolicyReport), and of the bars after them, are
+// undefined; they are not used for the mean and standard deviation, and are not spikes.
+func validateSpikes(rows []Data, sigma float64, find func(check Check, date time.Time, detail string, reportOnly bool) string) []Data {
+	if len(rows) < 3 {
+		return rows
+	}
+	returns := make([]float64, len(rows))
+	valid := make([]bool, len(rows))
+	mean, n := 0.0, 0
+	for i := 1; i < len(rows); i++ {
+		if !(rows[i].AdjClose > 0 && rows[i-1].AdjClose > 0) {
+			continue
+		}
+		returns[i], valid[i] = math.Log(rows[i].AdjClose/rows[i-1].AdjClose), true
+		mean += returns[i]
+		n++
+	}
+	if n < 2 {
+		return rows
+	}
+	mean /= float64(n)
+	variance := 0.0
+	for i := 1; i < len(rows); i++ {
+		if valid[i] {
+			variance += (returns[i] - mean) * (returns[i] - mean)
+		}
+	}
+	limit := sigma * math.Sqrt(variance/float64(n))
+
+	out := make([]Data, 0, len(rows))
+	out = append(out, rows[0])
+	for i := 1; i < len(rows); i++ {
+		if !valid[i] || math.Abs(returns[i]-mean) <= limit {
+			out = append(out, rows[i])
+			continue
+		}
+		reverts := i < len(rows)-1 && valid[i+1] && math.Abs(returns[i+1]-mean) > limit && math.Signbit(returns[i]) != math.Signbit(returns[i+1])
+		detail := fmt.Sprintf("return: %6.3f, reverts: %t", returns[i], reverts)
+		switch find(CheckSpike, rows[i].Date, detail, !reverts) {
+		case ActionDropped:
+			continue
+		case ActionRepaired:
+			prior := out[len(out)-1]
+			prior.Date, prior.Volume, prior.AdjVolume = rows[i].Date, rows[i].Volume, rows[i].AdjVolume
+			prior.Open, prior.High, prior.Low = prior.Close, prior.Close, prior.Close
+			prior.AdjOpen, prior.AdjHigh, prior.AdjLow = prior.AdjClose, prior.AdjClose, prior.AdjClose
+			out = append(out, prior)
+		default:
+			out = append(out, rows[i])
+		}
+		if reverts {
+			// The next bar reverts this spike and is not a spike itself.
+			out = append(out, rows[i+1])
+			i++
+		}
+	}
+	return out
+}
@@ -7,6 +7,7 @@ require (
 	github.com/paulfdunn/go-helper/logh/v2 v2.0.5
 	github.com/paulfdunn/go-helper/mathh/v2 v2.0.5
 	github.com/paulfdunn/go-helper/neth/v2 v2.0.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/paulfdunn/go-helper/osh/v2 v2.0.5 // indirect
@@ -0,0 +1,234 @@
+// Package jobs runs background jobs (I.E. downloads) that have an ID, report progress for
+// each item (I.E. symbol) of the job, and can be canceled. Only one job runs per key;
+// starting a job for a key that has a running job returns the running job, so concurrent
+// requests for the same work are deduplicated.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State is the state of a Job, or of an item of a Job.
+type State string
+
+const (
+	Pending   State = "pending"
+	Running   State = "running"
+	Succeeded State = "succeeded"
+	Failed    State = "failed"
+	Canceled  State = "canceled"
+)
+
+// RunFunc is the work of a job. It must return when ctx is done, and calls progress as each
+// item completes; err is nil on success. Items not given to Manager.Start are added.
+type RunFunc func(ctx context.Context, progress func(item string, err error)) error
+
+// Status is a copy of the state of a job; it is served as JSON.
+type Status struct {
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	State State  `json:"state"`
+	// Error is the error returned by the RunFunc.
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Total is the number of Items; Done and Failed count the Items that completed.
+	Total  int    `json:"total"`
+	Done   int    `json:"done"`
+	Failed int    `json:"failed"`
+	Items  []Item `json:"items"`
+}
+
+// Item is the progress of one item of a job.
+type Item struct {
+	Name  string `json:"name"`
+	State State  `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// Manager runs jobs and keeps the Status of running jobs and of the Keep most recently
+// finished jobs.
+type Manager struct {
+	Keep int
+
+	mutex    sync.Mutex
+	jobs     map[string]*job
+	running  map[string]*job
+	finished []string
+	lastID   int
+}
+
+type job struct {
+	status Status
+	index  map[string]int
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ErrNotFound is returned for a job ID that is not known to the Manager.
+var ErrNotFound = errors.New("job not found")
+
+// NewManager is a factory for Manager.
+func NewManager(keep int) *Manager {
+	return &Manager{Keep: keep, jobs: make(map[string]*job), running: make(map[string]*job)}
+}
+
+// Start runs run as a new job for key, with items as the initial Items, and returns its Status
+// and true. When a job for key is running its Status and false are returned, and run is not
+// called.
+func (m *Manager) Start(key string, items []string, run RunFunc) (Status, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if j, ok := m.running[key]; ok {
+		return j.copyStatus(), false
+	}
+
+	m.lastID++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: Status{ID: strconv.Itoa(m.lastID), Key: key, State: Running, Started: time.Now()},
+		index:  make(map[string]int),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	for _, item := range items {
+		j.addItem(item)
+	}
+	m.jobs[j.status.ID] = j
+	m.running[key] = j
+	go m.run(ctx, j, run)
+	return j.copyStatus(), true
+}
+
+// Cancel cancels the running job id; it is an error if the job has finished.
+func (m *Manager) Cancel(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if j.status.State != Running {
+		return fmt.Errorf("job: %s is not running, state: %s", id, j.status.State)
+	}
+	j.cancel()
+	return nil
+}
+
+// Get returns the Status of job id.
+func (m *Manager) Get(id string) (Status, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return j.copyStatus(), nil
+}
+
+// List returns the Status of all jobs, oldest first.
+func (m *Manager) List() []Status {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	list := make([]Status, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.copyStatus())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		idI, _ := strconv.Atoi(list[i].ID)
+		idJ, _ := strconv.Atoi(list[j].ID)
+		return idI < idJ
+	})
+	return list
+}
+
+// Wait blocks until job id finishes or ctx is done, and returns its Status.
+func (m *Manager) Wait(ctx context.Context, id string) (Status, error) {
+	m.mutex.Lock()
+	j, ok := m.jobs[id]
+	m.mutex.Unlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case <-j.done:
+		return m.Get(id)
+	}
+}
+
+// run calls run and records the result; finished jobs past Keep are removed.
+func (m *Manager) run(ctx context.Context, j *job, run RunFunc) {
+	defer close(j.done)
+	progress := func(item string, err error) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		j.setItem(item, err)
+	}
+	err := run(ctx, progress)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	j.status.Finished = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		j.status.State = Canceled
+	case err != nil:
+		j.status.State = Failed
+	default:
+		j.status.State = Succeeded
+	}
+	if err != nil {
+		j.status.Error = err.Error()
+	}
+	j.cancel()
+	delete(m.running, j.status.Key)
+	m.finished = append(m.finished, j.status.ID)
+	for len(m.finished) > m.Keep {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+}
+
+func (j *job) addItem(name string) int {
+	if i, ok := j.index[name]; ok {
+		return i
+	}
+	j.index[name] = len(j.status.Items)
+	j.status.Items = append(j.status.Items, Item{Name: name, State: Pending})
+	j.status.Total++
+	return j.index[name]
+}
+
+// copyStatus returns a copy of the Status that does not share Items with j.
+func (j *job) copyStatus() Status {
+	status := j.status
+	status.Items = append([]Item(nil), j.status.Items...)
+	return status
+}
+
+// setItem records the result of item. An item reported again (I.E. retried) replaces the
+// prior result.
+func (j *job) setItem(name string, err error) {
+	item := &j.status.Items[j.addItem(name)]
+	switch item.State {
+	case Succeeded:
+		j.status.Done--
+	case Failed:
+		j.status.Done--
+		j.status.Failed--
+	}
+	item.State, item.Error = Succeeded, ""
+	if err != nil {
+		item.State, item.Error = Failed, err.Error()
+		j.status.Failed++
+	}
+	j.status.Done++
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func ExampleManager() {
+	m := NewManager(1)
+	release := make(chan struct{})
+	download := func(ctx context.Context, progress func(item string, err error)) error {
+		progress("dia", nil)
+		progress("spy", errors.New("HTTP status: 404 Not Found"))
+		progress("eurusd=x", nil)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-release:
+			return nil
+		}
+	}
+	printStatus := func(status Status) {
+		fmt.Printf("id: %s, key: %s, state: %s, done: %d/%d, failed: %d, error: %q\n", status.ID, status.Key,
+			status.State, status.Done, status.Total, status.Failed, status.Error)
+	}
+
+	first, started := m.Start("etfs", []string{"dia", "spy", "qqq"}, download)
+	fmt.Println("started:", started)
+	printStatus(first)
+	// A second request for the same key returns the running job.
+	second, started := m.Start("etfs", []string{"dia", "spy", "qqq"}, download)
+	fmt.Println("started:", started, "id:", second.ID)
+	close(release)
+	status, _ := m.Wait(context.Background(), first.ID)
+	printStatus(status)
+	fmt.Printf("%+v\n", status.Items)
+	fmt.Println(m.Cancel(first.ID))
+
+	third, _ := m.Start("etfs", []string{"dia"}, func(ctx context.Context, progress func(item string, err error)) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	fmt.Println(m.Cancel(third.ID))
+	status, _ = m.Wait(context.Background(), third.ID)
+	printStatus(status)
+	// Only the last finished job is kept.
+	_, err := m.Get(first.ID)
+	fmt.Println(err, len(m.List()))
+
+	// Output:
+	// started: true
+	// id: 1, key: etfs, state: running, done: 0/3, failed: 0, error: ""
+	// started: false id: 1
+	// id: 1, key: etfs, state: succeeded, done: 3/4, failed: 1, error: ""
+	// [{Name:dia State:succeeded Error:} {Name:spy State:failed Error:HTTP status: 404 Not Found} {Name:qqq State:pending Error:} {Name:eurusd=x State:succeeded Error:}]
+	// job: 1 is not running, state: succeeded
+	// <nil>
+	// id: 2, key: etfs, state: canceled, done: 0/1, failed: 0, error: "context canceled"
+	// job not found 1
+}
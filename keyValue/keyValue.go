@@ -0,0 +1,27 @@
+// Package keyValue parses specs of comma separated key=value pairs; I.E. the options of a price
+// source or trading cost model given on the command line.
+package keyValue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse calls set with each pair of spec, a comma separated list of key=value pairs; I.E.
+// "seed=7,volatility=0.2". Keys are passed to set in lower case, and keys and values are trimmed
+// of spaces. The error names the pair that could not be parsed or set.
+func Parse(spec string, set func(key string, value string) error) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%s, expected key=value", pair)
+		}
+		if err := set(strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%s, error: %v", pair, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package keyValue
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func ExampleParse() {
+	var seed int
+	var model string
+	set := func(key string, value string) (err error) {
+		switch key {
+		case "seed":
+			seed, err = strconv.Atoi(value)
+		case "model":
+			model = value
+		default:
+			return fmt.Errorf("unknown key")
+		}
+		return err
+	}
+	err := Parse(" Seed=7, model = jump,", set)
+	fmt.Println(seed, model, err)
+	fmt.Println(Parse("seed", set))
+	fmt.Println(Parse("seed=x", set))
+	fmt.Println(Parse("volatility=0.2", set))
+
+	// Output:
+	// 7 jump <nil>
+	// seed, expected key=value
+	// seed=x, error: strconv.Atoi: parsing "x": invalid syntax
+	// volatility=0.2, error: unknown key
+}
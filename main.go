@@ -1,28 +1,48 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/paulfdunn/go-helper/logh/v2"
+	"github.com/paulfdunn/go-quantstudio/calendar"
 	"github.com/paulfdunn/go-quantstudio/defs"
 	"github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/downloader/csvDirectory"
+	"github.com/paulfdunn/go-quantstudio/downloader/deprecated/financeYahoo"
 	"github.com/paulfdunn/go-quantstudio/downloader/financeYahooChart"
+	"github.com/paulfdunn/go-quantstudio/downloader/synthetic"
+	"github.com/paulfdunn/go-quantstudio/jobs"
 	"github.com/paulfdunn/go-quantstudio/quant"
+	"github.com/paulfdunn/go-quantstudio/quant/metrics"
 	"github.com/paulfdunn/go-quantstudio/quant/quantCvO"
 	"github.com/paulfdunn/go-quantstudio/quant/quantMA2"
 	"github.com/paulfdunn/go-quantstudio/quant/quantMAH"
+	"github.com/paulfdunn/go-quantstudio/scheduler"
+	"github.com/paulfdunn/go-quantstudio/universe"
+)
+
+const (
+	// downloadJobsKeep is the number of finished download jobs kept for the jobs endpoint.
+	downloadJobsKeep = 50
 )
 
 var (
@@ -32,22 +52,57 @@ var (
 	lpf     func(level logh.LoghLevel, format string, v ...interface{})
 
 	// CLI flags
-	liveDataPtr, runMARangePtr              *bool
-	groupNamePtr, logFilePtr, symbolCSVList *string
-	logLevel                                *int
+	holdingCostPtr, incrementalPtr, liveDataPtr, profilesPtr           *bool
+	runMARangePtr, snapshotsPtr                                        *bool
+	asOfPtr, baseCurrencyPtr, groupNamePtr, logFilePtr, symbolCSVList  *string
+	csvPtr, groupsPtr, intervalPtr, missingBarsPtr, portPtr, sourcePtr *string
+	refreshPtr, syntheticPtr, universePtr                              *string
+	logLevel, retriesPtr, snapshotKeepPtr, snapshotMaxAgeDaysPtr       *int
+	rateLimitPtr, riskFreePtr, spikeSigmaPtr                           *float64
+	cashPtr, costsPtr, costsCvOPtr, costsMA2Ptr, costsMAHPtr, taxPtr   *string
+	validationPtr                                                      *string
 
 	// dataDirectorySuffix is appended to the users home directory.
 	dataDirectorySuffix = filepath.Join(`tmp`, appName)
 	dataDirectory       string
+	// snapshotDirectory is the directory in dataDirectory holding the snapshots.
+	snapshotDirectory = "snapshots"
 
-	dlGroupChanCvO chan *downloader.Group
-	dlGroupChanMA  chan *downloader.Group
-	dlGroupChanMA2 chan *downloader.Group
+	// dlSource is the downloader.Downloader selected with the source flag.
+	dlSource downloader.Downloader
+	// cashSymbol is the symbol set with the cash flag, downloaded with every group for the
+	// return of cash; empty when the flag is a rate or not set.
+	cashSymbol string
+	// asOf is the end of the day set with the asof flag; zero when not set.
+	asOf time.Time
+	// snapshotStore holds snapshots of all downloaded data. Snapshots are only saved
+	// when the snapshots flag is set.
+	snapshotStore *downloader.Store
+	// symbolGroups are the universe groups selected with the groups flag (or the symbolCSVList
+	// flag), and serverGroups are the same groups as served by the GUI.
+	symbolGroups []universe.Group
+	serverGroups []*serverGroup
+	// dataCache holds the downloaded data of every serverGroup, keyed by the group name; the
+	// handlers read from dataCache, and downloads replace the data.
+	dataCache = downloader.NewCache()
+	// downloadJobs runs the downloads requested with the GUI and by the scheduled refresh; only
+	// one download per group runs at a time.
+	downloadJobs = jobs.NewManager(downloadJobsKeep)
 
 	//go:embed assets/chartCvO assets/chartMAH assets/chartMA2 assets/index.html assets/plotly-2.16.1.min.js assets/script.js
 	staticFS embed.FS
 )
 
+// serverGroup is a group of symbols served by the GUI; each group is downloaded to its own data
+// file.
+type serverGroup struct {
+	symbols      universe.Group
+	dataFilepath string
+	// downloadMutex serializes downloads of the group (I.E. the download button and a scheduled
+	// refresh), as they write the same data file.
+	downloadMutex sync.Mutex
+}
+
 func crashDetect() {
 	if err := recover(); err != nil {
 		errOut := fmt.Sprintf("panic: %+v\n%+v", err, string(debug.Stack()))
@@ -73,13 +128,67 @@ func Init() {
 	}
 
 	// CLI flags
-	groupNamePtr = flag.String("groupname", "ETFs", "Name for this group of symbols. Used for naming output files when processing groups of symbols. I.E. maybe you want to download/analyze stocks separately from ETFs")
+	asOfPtr = flag.String("asof", "", "Date (YYYY-MM-DD); load the newest snapshot of the data saved on or before this date instead of the data from the prior call. "+
+		"Used to reproduce prior results. (Using the download button in the GUI will ALWAYS download new data.)")
+	baseCurrencyPtr = flag.String("baseCurrency", "", "Currency (I.E. USD) to which all symbols are converted, using downloaded FX rates; "+
+		"trade histories then show gains in both the local and base currency. Blank for no conversion.")
+	cashPtr = flag.String("cash", "", "Return of cash while no trade is open: an annual rate in percent (I.E. 4.5), or a symbol downloaded with every group, "+
+		"either a money-market ETF (I.E. sgov) or a yield index starting with ^ (I.E. ^irx). Blank for no return.")
+	costsPtr = flag.String("costs", "", "Trading costs paid by every strategy on each fill, as comma separated key=value pairs; I.E. commission=1,spreadBps=5,slippage=volume,slippageFactor=0.1. "+
+		"Keys are tradeSize (value of each trade; default 10000), commission (fixed, per fill), perShare (commission per share), spreadBps (bid-ask spread in basis points), "+
+		"slippage (none, volume, or volatility), and slippageFactor. Blank for no costs.")
+	costsCvOPtr = flag.String("costsCvO", "", "Trading costs of the CvO strategy, in the format of -costs, overriding -costs; blank to use -costs.")
+	costsMA2Ptr = flag.String("costsMA2", "", "Trading costs of the MA2 strategy, in the format of -costs, overriding -costs; blank to use -costs.")
+	costsMAHPtr = flag.String("costsMAH", "", "Trading costs of the MAH strategy, in the format of -costs, overriding -costs; blank to use -costs.")
+	csvPtr = flag.String("csv", "", "Options of the "+csvDirectory.SourceName+" source, as comma separated key=value pairs; I.E. "+
+		"date=Trade Date,close=Last,comma=;,dateFormat=01/02/2006. Keys are directory, extension, comma, dateFormat, and the column "+
+		"(header name) of each field: date, open, high, low, close, volume, adjOpen, adjHigh, adjLow, adjClose, adjVolume.")
+	groupNamePtr = flag.String("groupname", "ETFs", "Name for the group of symbols given with -symbolCSVList. Used for naming output files.")
+	groupsPtr = flag.String("groups", defs.UniverseGroupsDefault, "Comma separated list of the groups in the universe file for which to download prices. "+
+		"Each group is saved in its own data file, named with the group name, and the GUI can switch between them.")
+	holdingCostPtr = flag.Bool("holdingCost", false, "Charge long trades the annual expense ratio of the symbol, pro rata for the time the trade is open. "+
+		"The expense ratio is from the downloaded profile (see -profiles), or the universe file. This is an extra cost; adjusted prices of ETFs are already net of the expense ratio.")
+	incrementalPtr = flag.Bool("incremental", false, "When getting live data, only download data after the last data point in the file created during the prior call. "+
+		"Symbols with changed prior prices (I.E. a split or dividend) are downloaded in full.")
+	intervalPtr = flag.String("interval", string(downloader.Interval1d), fmt.Sprintf("Interval of each price bar; one of: %v. "+
+		"Intraday data is limited to the most recent data Yahoo provides (7 days for 1m, 60 days for 5m and 15m, 730 days for 1h).", downloader.Intervals()))
 	liveDataPtr = flag.Bool("livedata", true, "Get live data; otherwise load from file created during prior call. (Using the download button in the GUI will ALWAYS download new data.)")
 	logFilePtr = flag.String("logfile", "", "Name of log file in "+dataDirectory+"; blank to print logs to terminal.")
 	logLevel = flag.Int("loglevel", int(logh.Info), fmt.Sprintf("Logging level; default %d. Zero based index into: %v",
 		int(logh.Info), logh.DefaultLevels))
+	missingBarsPtr = flag.String("missingBars", downloader.MissingDrop.String(), fmt.Sprintf("Handling of bars with missing (null) prices; one of: %s, %s, %s. "+
+		"%s keeps the bar with NaN values for validation to repair or drop; it requires -validation %s or %s.",
+		downloader.MissingDrop, downloader.MissingForwardFill, downloader.MissingMark, downloader.MissingMark,
+		downloader.PolicyRepair, downloader.PolicyDrop))
+	portPtr = flag.String("port", defs.GUIPort, "Address (I.E. :8080) on which the GUI is served.")
+	profilesPtr = flag.Bool("profiles", false, "Download the profile of each symbol (ETF expense ratio, AUM, dividend yield, and holdings; "+
+		"stock fundamentals) with the prices, when supported by the source. Profiles are served on /profiles.")
+	rateLimitPtr = flag.Float64("rateLimit", 2, "Maximum number of download requests per second; 0 for no limit.")
+	refreshPtr = flag.String("refresh", "", "Schedule on which the server downloads new data for all groups; a cron expression "+
+		"(minute hour day-of-month month day-of-week, in exchange time) or "+scheduler.MarketClose+"[+duration] for after every NYSE market close, "+
+		"I.E. "+scheduler.MarketClose+"+1h. Blank to not refresh. Not used with -asof, -livedata=false, or -runMArange.")
+	retriesPtr = flag.Int("retries", 3, "Number of times a failed download request is retried, with exponential backoff. "+
+		"Symbols that still fail are skipped and reported.")
+	riskFreePtr = flag.Float64("riskFree", 0, "Annual risk free rate, in percent, used for the Sharpe and Sortino ratios.")
 	runMARangePtr = flag.Bool("runMArange", false, "When true, runs a range of moving average parameters and exits.")
-	symbolCSVList = flag.String("symbolCSVList", defs.TradingSymbolsDefault, "Comma separated list of symbols for which to download prices")
+	snapshotsPtr = flag.Bool("snapshots", true, "Save a timestamped snapshot of the data after every download, in "+filepath.Join(dataDirectory, snapshotDirectory)+".")
+	snapshotKeepPtr = flag.Int("snapshotKeep", 60, "Maximum number of snapshots kept per group; 0 for no limit.")
+	snapshotMaxAgeDaysPtr = flag.Int("snapshotMaxAgeDays", 0, "Snapshots older than this number of days are removed (the newest is always kept); 0 for no limit.")
+	sourcePtr = flag.String("source", financeYahooChart.SourceName, fmt.Sprintf("Name of the source used to download prices; one of: %s, %s, %s, %s. "+
+		"%s loads <symbol>.csv files, in the format written by the downloader (see -csv), from directory %s/<groupname>. %s generates prices; see -synthetic.",
+		financeYahooChart.SourceName, financeYahoo.SourceName, csvDirectory.SourceName, synthetic.SourceName, csvDirectory.SourceName, dataDirectory,
+		synthetic.SourceName))
+	spikeSigmaPtr = flag.Float64("spikeSigma", 8, "Validation flags bar to bar returns more than this number of standard deviations from the mean as spikes; 0 disables the check.")
+	syntheticPtr = flag.String("synthetic", "", "Options of the "+synthetic.SourceName+" source, as comma separated key=value pairs; I.E. model=jump,seed=7,volatility=0.2. "+
+		"Models: gbm (default), regime, jump, bootstrap (resamples the returns of a prior download, returnsFrom=<data file path>). "+
+		"Other keys: start, end, startPrice, drift, volatility, regimes (drift:volatility:meanBars/...), jumpsPerYear, jumpMean, jumpStdDev, blockSize.")
+	symbolCSVList = flag.String("symbolCSVList", "", "Comma separated list of symbols for which to download prices; when set, used instead of -groups.")
+	taxPtr = flag.String("tax", "", "Report after-tax gains, using tax rates in percent as comma separated key=value pairs; I.E. shortTerm=32,longTerm=15,washSale=true. "+
+		"Keys are shortTerm (default 37), longTerm (default 20), longTermDays (default 365), washSale (losses followed by a buy within washSaleDays are flagged and do not reduce tax), "+
+		"and washSaleDays (default 30). Blank for pre-tax gains only.")
+	universePtr = flag.String("universe", "", "Universe file (.yaml, .yml, or .json) of named groups of symbols; blank for the built in universe (universe/default.yaml).")
+	validationPtr = flag.String("validation", downloader.PolicyRepair.String(), fmt.Sprintf("Data validation policy; one of: off, %s, %s, %s, %s",
+		downloader.PolicyReport, downloader.PolicyRepair, downloader.PolicyDrop, downloader.PolicyFail))
 	flag.Parse()
 
 	var logFilepath string
@@ -97,15 +206,105 @@ func Init() {
 	lpf(logh.Info, "Data and logs being saved to directory: %s", dataDirectory)
 
 	downloader.Init(appName)
+	downloader.BaseCurrency = strings.ToUpper(*baseCurrencyPtr)
+	downloader.Incremental = *incrementalPtr
+	downloader.RateLimit = downloader.NewRateLimiter(*rateLimitPtr, 1)
+	downloader.MissingBars, err = downloader.ParseMissingBarPolicy(*missingBarsPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	downloader.Retry.MaxAttempts = *retriesPtr + 1
+	downloader.BarInterval, err = downloader.ParseInterval(*intervalPtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *validationPtr != "off" {
+		policy, err := downloader.ParseValidationPolicy(*validationPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		downloader.Validation = &downloader.ValidationRules{Policy: policy, SpikeSigma: *spikeSigmaPtr}
+	}
+	if err := downloader.CheckMissingBars(downloader.MissingBars, downloader.Validation); err != nil {
+		log.Fatal(err)
+	}
+	retention := downloader.Retention{KeepLast: *snapshotKeepPtr, MaxAge: time.Duration(*snapshotMaxAgeDaysPtr) * 24 * time.Hour}
+	snapshotStore, err = downloader.NewStore(filepath.Join(dataDirectory, snapshotDirectory), retention)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *snapshotsPtr {
+		downloader.SnapshotStore = snapshotStore
+	}
+	if *asOfPtr != "" {
+		asOfDate, err := time.ParseInLocation(downloader.DateFormat, *asOfPtr, time.Local)
+		if err != nil {
+			log.Fatal(err)
+		}
+		asOf = asOfDate.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		*liveDataPtr = false
+	}
+	csvDirectory.Init(appName)
+	csvDirectory.Defaults, err = csvDirectory.ParseOptions(*csvPtr, csvDirectory.Defaults)
+	if err != nil {
+		log.Fatal(err)
+	}
+	financeYahoo.Init(appName)
 	financeYahooChart.Init(appName)
+	synthetic.Init(appName)
+	synthetic.Defaults, err = synthetic.ParseOptions(*syntheticPtr, synthetic.Defaults)
+	if err != nil {
+		log.Fatal(err)
+	}
 	quant.Init(appName)
+	quant.HoldingCost = *holdingCostPtr
+	metrics.RiskFree = *riskFreePtr
+	if rate, errRate := strconv.ParseFloat(*cashPtr, 64); errRate == nil {
+		quant.CashRate = rate
+	} else {
+		cashSymbol = strings.ToLower(strings.TrimSpace(*cashPtr))
+	}
+	if *taxPtr != "" {
+		taxRates, err := quant.ParseTaxRates(*taxPtr, quant.TaxRatesDefault)
+		if err != nil {
+			log.Fatal(err)
+		}
+		quant.Tax = &taxRates
+	}
+	if quantCvO.Costs, err = strategyCosts(*costsCvOPtr); err != nil {
+		log.Fatal(err)
+	}
+	if quantMA2.Costs, err = strategyCosts(*costsMA2Ptr); err != nil {
+		log.Fatal(err)
+	}
+	if quantMAH.Costs, err = strategyCosts(*costsMAHPtr); err != nil {
+		log.Fatal(err)
+	}
 	quantCvO.Init(appName)
 	quantMAH.Init(appName)
 	quantMA2.Init(appName)
 
-	dlGroupChanCvO = make(chan *downloader.Group, 1)
-	dlGroupChanMA = make(chan *downloader.Group, 1)
-	dlGroupChanMA2 = make(chan *downloader.Group, 1)
+	symbolGroups, err = loadSymbolGroups()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, group := range symbolGroups {
+		if len(group.TradingSymbols()) == 0 {
+			log.Fatalf("no trading symbols in group: %s", group.Name)
+		}
+		if _, ok := group.Entry(cashSymbol); cashSymbol != "" && !ok {
+			group.Symbols = append(group.Symbols, universe.Entry{Symbol: cashSymbol, Description: "Cash return while no trade is open",
+				AssetClass: "cash", AnalysisOnly: true})
+			symbolGroups[i] = group
+		}
+		serverGroups = append(serverGroups, &serverGroup{symbols: group, dataFilepath: filepath.Join(dataDirectory, group.Name)})
+	}
+
+	dlSource, err = downloader.Source(*sourcePtr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lpf(logh.Info, "Using source: %s", *sourcePtr)
 }
 
 func main() {
@@ -113,61 +312,80 @@ func main() {
 
 	Init()
 
-	dataFilepath := filepath.Join(dataDirectory, *groupNamePtr)
-	tradingSymbols := strings.Split(*symbolCSVList, ",")
-
 	// adapted from https://github.com/353words/stocks/blob/main/index.html
 	fsSub, err := fs.Sub(staticFS, "assets")
 	if err != nil {
 		lpf(logh.Error, "calling fs.Sub: %+v", err)
 	}
+	handlersCvO := make(map[string]http.HandlerFunc)
+	handlersMAH := make(map[string]http.HandlerFunc)
+	handlersMA2 := make(map[string]http.HandlerFunc)
+	for _, sg := range serverGroups {
+		tradingSymbols := sg.symbols.TradingSymbols()
+		handlersCvO[sg.symbols.Name] = quantCvO.WrappedPlotlyHandler(dataCache, sg.symbols.Name, tradingSymbols)
+		handlersMAH[sg.symbols.Name] = quantMAH.WrappedPlotlyHandler(dataCache, sg.symbols.Name, tradingSymbols)
+		handlersMA2[sg.symbols.Name] = quantMA2.WrappedPlotlyHandler(dataCache, sg.symbols.Name, tradingSymbols)
+	}
 	http.Handle("/", http.FileServer(http.FS(fsSub)))
-	http.HandleFunc("/plotly-cvo", quantCvO.WrappedPlotlyHandler(dlGroupChanCvO, tradingSymbols))
-	http.HandleFunc("/plotly-mah", quantMAH.WrappedPlotlyHandler(dlGroupChanMA, tradingSymbols))
-	http.HandleFunc("/plotly-ma2", quantMA2.WrappedPlotlyHandler(dlGroupChanMA2, tradingSymbols))
-	http.HandleFunc("/downloadData", wrappedDownloadYahooData(dataFilepath, tradingSymbols, dlGroupChanCvO, dlGroupChanMA, dlGroupChanMA2))
-	http.HandleFunc("/symbols", wrappedSymbols(tradingSymbols))
-
-	// Download data and put it in channels
-	err = downloadYahooData(*liveDataPtr, dataFilepath, tradingSymbols, dlGroupChanCvO, dlGroupChanMA, dlGroupChanMA2)
-	if err != nil {
-		lpf(logh.Error, "calling downloadYahooData: %+v", err)
-		lp(logh.Error, "exiting...")
-		os.Exit(0)
+	http.HandleFunc("/plotly-cvo", wrappedGroupHandler(handlersCvO))
+	http.HandleFunc("/plotly-mah", wrappedGroupHandler(handlersMAH))
+	http.HandleFunc("/plotly-ma2", wrappedGroupHandler(handlersMA2))
+	http.HandleFunc("/downloadData", wrappedDownloadData())
+	http.HandleFunc("/groups", wrappedGroups(symbolGroups))
+	http.HandleFunc("/jobs", wrappedJobs())
+	http.HandleFunc("/jobs/", wrappedJobs())
+	http.HandleFunc("/profiles", wrappedProfiles())
+	http.HandleFunc("/symbols", wrappedSymbols(symbolGroups))
+
+	// Download data and put it in dataCache
+	for _, sg := range serverGroups {
+		err = downloadData(context.Background(), *liveDataPtr, sg)
+		if err != nil {
+			lpf(logh.Error, "calling downloadData: %+v", err)
+			lp(logh.Error, "exiting...")
+			os.Exit(0)
+		}
 	}
 
 	if *runMARangePtr || false {
-		runMARange(tradingSymbols)
+		runMARange(serverGroups[0])
 		lp(logh.Info, "runMARange complete...")
 		lp(logh.Error, "exiting...")
 		os.Exit(0)
 	}
 
-	// Fire the handler once to run the data. This is just so the log file has the
-	// latest trade information.
-	targetCvO := fmt.Sprintf("/plotly-cvo?symbol=%s&maLength=%d&maSplit=%f", tradingSymbols[0], defs.CvOLengthDefault, defs.CvOSplitDefault)
-	reqCvO := httptest.NewRequest(http.MethodGet, targetCvO, nil)
-	wCvO := httptest.NewRecorder()
-	quantCvO.WrappedPlotlyHandler(dlGroupChanCvO, tradingSymbols)(wCvO, reqCvO)
-	targetMAH := fmt.Sprintf("/plotly-mah?symbol=%s&maLength=%d&maSplit=%f&maShortShift=%05.2f&stopLoss=%05.2f&stopLossDelay=%d&longQuickBuy=%t&ema=%t", tradingSymbols[0], defs.MAHLengthDefault, defs.MAHSplitDefault, defs.MAHShortShiftDefault, defs.MAHStopLoss, defs.MAHStopLossDelay, defs.MAHLongQuickBuy, defs.MAHEMA)
-	reqMAH := httptest.NewRequest(http.MethodGet, targetMAH, nil)
-	wMAH := httptest.NewRecorder()
-	quantMAH.WrappedPlotlyHandler(dlGroupChanMA, tradingSymbols)(wMAH, reqMAH)
-	targetMA2 := fmt.Sprintf("/plotly-ma2?symbol=%s&maLengthLF=%d&maLengthHF=%d&maShortShift=%05.2f&stopLoss=%05.2f&stopLossDelay=%d&longQuickBuy=%t&ema=%t", tradingSymbols[0], defs.MA2LengthDefaultLF, defs.MA2LengthDefaultHF, defs.MA2ShortShiftDefault, defs.MA2StopLoss, defs.MA2StopLossDelay, defs.MA2LongQuickBuy, defs.MA2EMA)
-	reqMA2 := httptest.NewRequest(http.MethodGet, targetMA2, nil)
-	wMA2 := httptest.NewRecorder()
-	quantMA2.WrappedPlotlyHandler(dlGroupChanMA2, tradingSymbols)(wMA2, reqMA2)
-	// Download again (livedata is false, so this is loading the data downloaded above from file)
-	// as the above call consumed the data from the channel and the registered
-	// handler will not have data without calling downloadYahooData again.
-	if err := downloadYahooData(false, dataFilepath, tradingSymbols, dlGroupChanCvO, dlGroupChanMA, dlGroupChanMA2); err != nil {
-		log.Fatal(err)
+	for _, sg := range serverGroups {
+		// Fire the handler once to run the data. This is just so the log file has the
+		// latest trade information.
+		tradingSymbols := sg.symbols.TradingSymbols()
+		targetCvO := fmt.Sprintf("/plotly-cvo?symbol=%s&maLength=%d&maSplit=%f", tradingSymbols[0], defs.CvOLengthDefault, defs.CvOSplitDefault)
+		reqCvO := httptest.NewRequest(http.MethodGet, targetCvO, nil)
+		wCvO := httptest.NewRecorder()
+		handlersCvO[sg.symbols.Name](wCvO, reqCvO)
+		targetMAH := fmt.Sprintf("/plotly-mah?symbol=%s&maLength=%d&maSplit=%f&maShortShift=%05.2f&stopLoss=%05.2f&stopLossDelay=%d&longQuickBuy=%t&ema=%t", tradingSymbols[0], defs.MAHLengthDefault, defs.MAHSplitDefault, defs.MAHShortShiftDefault, defs.MAHStopLoss, defs.MAHStopLossDelay, defs.MAHLongQuickBuy, defs.MAHEMA)
+		reqMAH := httptest.NewRequest(http.MethodGet, targetMAH, nil)
+		wMAH := httptest.NewRecorder()
+		handlersMAH[sg.symbols.Name](wMAH, reqMAH)
+		targetMA2 := fmt.Sprintf("/plotly-ma2?symbol=%s&maLengthLF=%d&maLengthHF=%d&maShortShift=%05.2f&stopLoss=%05.2f&stopLossDelay=%d&longQuickBuy=%t&ema=%t", tradingSymbols[0], defs.MA2LengthDefaultLF, defs.MA2LengthDefaultHF, defs.MA2ShortShiftDefault, defs.MA2StopLoss, defs.MA2StopLossDelay, defs.MA2LongQuickBuy, defs.MA2EMA)
+		reqMA2 := httptest.NewRequest(http.MethodGet, targetMA2, nil)
+		wMA2 := httptest.NewRecorder()
+		handlersMA2[sg.symbols.Name](wMA2, reqMA2)
+	}
+
+	// Refreshing downloads live data, so it is only scheduled when live data is used.
+	if *refreshPtr != "" && asOf.IsZero() && *liveDataPtr {
+		schedule, err := scheduler.Parse(*refreshPtr, calendar.NYSE)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lpf(logh.Info, "Data refresh scheduled: %s, next refresh: %s", *refreshPtr, schedule.Next(time.Now()))
+		go scheduler.Run(context.Background(), schedule, refreshData)
 	}
 
 	lp(logh.Info, "******************************************************")
-	lp(logh.Info, "GUI running, open a browser to http://localhost"+defs.GUIPort+",  CTRL-C to stop")
+	lp(logh.Info, "GUI running, open a browser to http://localhost"+*portPtr+",  CTRL-C to stop")
 	lp(logh.Info, "******************************************************\n\n")
-	if err := http.ListenAndServe(defs.GUIPort, nil); err != nil {
+	if err := http.ListenAndServe(*portPtr, nil); err != nil {
 		log.Fatal(err)
 	}
 
@@ -178,29 +396,150 @@ func main() {
 	}
 }
 
-func downloadYahooData(liveData bool, dataFilepath string, tradingSymbols []string,
-	dlGroupChanCvO chan *downloader.Group, dlGroupChanMA chan *downloader.Group,
-	dlGroupChanMA2 chan *downloader.Group) error {
-	allSymbols := tradingSymbols
-	if defs.AnalysisSymbols != "" {
-		allSymbols = append(allSymbols, strings.Split(defs.AnalysisSymbols, ",")...)
+// downloadData downloads (or loads) the data for sg and puts it in dataCache. On error (or when
+// ctx is canceled) the data in dataCache is not replaced.
+func downloadData(ctx context.Context, liveData bool, sg *serverGroup) error {
+	sg.downloadMutex.Lock()
+	defer sg.downloadMutex.Unlock()
+	allSymbols := sg.symbols.AllSymbols()
+	lpf(logh.Info, "Downloading group: %s, these symbols: %+v", sg.symbols.Name, allSymbols)
+	var group *downloader.Group
+	var err error
+	if !liveData && !asOf.IsZero() {
+		var snapshot downloader.Snapshot
+		group, snapshot, err = snapshotStore.LoadAsOf(downloader.IntervalKey(sg.symbols.Name, downloader.BarInterval), asOf)
+		if err == nil {
+			lpf(logh.Warning, "Data loaded from snapshot: %s", snapshot.FilePath)
+		}
+	} else {
+		group, err = downloader.NewGroupFromSource(ctx, dlSource, liveData, sg.dataFilepath, sg.symbols.Name, allSymbols)
 	}
-	lpf(logh.Info, "Downloading these symbols: %+v", allSymbols)
-	group, err := financeYahooChart.NewGroup(liveData, dataFilepath, *groupNamePtr, allSymbols)
 	lp(logh.Info, "Downloading complete")
-	dlGroupChanCvO <- group
-	dlGroupChanMA <- group
-	dlGroupChanMA2 <- group
 	if err != nil {
 		lpf(logh.Error, "calling NewGroup: %+v", err)
 		return err
 	}
+	group.SetProfiles(loadProfiles(ctx, liveData, sg))
+	if cashSymbol != "" {
+		if err := group.SetCash(cashSymbol, strings.HasPrefix(cashSymbol, "^")); err != nil {
+			lpf(logh.Error, "cash returns not set: %+v", err)
+		}
+	}
+	entry := dataCache.Set(sg.symbols.Name, group)
+	lpf(logh.Info, "Group: %s, data version: %d", sg.symbols.Name, entry.Version)
 	return nil
 }
 
+// latestSignals returns the last trade value (quant.LongBuy, etc.) of every trading symbol of sg
+// in dlGroup for each strategy, run with the default parameters; keyed by strategy then symbol.
+func latestSignals(sg *serverGroup, dlGroup *downloader.Group) map[string]map[string]int {
+	tradingSymbols := sg.symbols.TradingSymbols()
+	signals := map[string]map[string]int{"CvO": {}, "MAH": {}, "MA2": {}}
+	for _, qIssue := range quantCvO.GetGroup(dlGroup, tradingSymbols, defs.CvOLengthDefault, defs.CvOSplitDefault).Issues {
+		if trade := qIssue.QuantsetAsColumns.Results.Trade; len(trade) > 0 {
+			signals["CvO"][qIssue.DownloaderIssue.Symbol] = trade[len(trade)-1]
+		}
+	}
+	for _, qIssue := range quantMAH.GetGroup(dlGroup, tradingSymbols, defs.MAHLengthDefault, defs.MAHSplitDefault, defs.MAHShortShiftDefault,
+		defs.MAHStopLoss, defs.MAHStopLossDelay, defs.MAHLongQuickBuy, defs.MAHEMA).Issues {
+		if trade := qIssue.QuantsetAsColumns.Results.Trade; len(trade) > 0 {
+			signals["MAH"][qIssue.DownloaderIssue.Symbol] = trade[len(trade)-1]
+		}
+	}
+	for _, qIssue := range quantMA2.GetGroup(dlGroup, tradingSymbols, defs.MA2LengthDefaultLF, defs.MA2LengthDefaultHF, defs.MA2ShortShiftDefault,
+		defs.MA2StopLoss, defs.MA2StopLossDelay, defs.MA2LongQuickBuy, defs.MA2EMA).Issues {
+		if trade := qIssue.QuantsetAsColumns.Results.Trade; len(trade) > 0 {
+			signals["MA2"][qIssue.DownloaderIssue.Symbol] = trade[len(trade)-1]
+		}
+	}
+	return signals
+}
+
+// loadProfiles returns the profiles of the symbols of sg. Profiles are downloaded (or loaded
+// from the file saved by the prior download) when the profiles flag is set and the source is a
+// downloader.ProfileDownloader; errors are logged. The expense ratio in the universe file is
+// used for symbols without one.
+func loadProfiles(ctx context.Context, liveData bool, sg *serverGroup) map[string]downloader.Profile {
+	profiles := make(map[string]downloader.Profile)
+	if pd, ok := dlSource.(downloader.ProfileDownloader); ok && *profilesPtr {
+		var err error
+		if profiles, err = pd.NewProfiles(ctx, liveData, sg.dataFilepath, sg.symbols.AllSymbols()); err != nil {
+			lpf(logh.Warning, "Profiles not loaded; group: %s, error: %+v", sg.symbols.Name, err)
+			profiles = make(map[string]downloader.Profile)
+		}
+	}
+	for _, entry := range sg.symbols.Symbols {
+		profile := profiles[entry.Symbol]
+		if profile.ExpenseRatio == 0 && entry.ExpenseRatio > 0 {
+			profile.ExpenseRatio = entry.ExpenseRatio
+			profiles[entry.Symbol] = profile
+		}
+	}
+	return profiles
+}
+
+// loadSymbolGroups returns the groups selected with the groups flag from the universe file, or
+// a single group named with the groupname flag when the symbolCSVList flag is set.
+func loadSymbolGroups() ([]universe.Group, error) {
+	if *symbolCSVList != "" {
+		return []universe.Group{universe.FromCSV(*groupNamePtr, *symbolCSVList)}, nil
+	}
+	var u *universe.Universe
+	var err error
+	if *universePtr == "" {
+		u, err = universe.Default()
+	} else {
+		u, err = universe.Load(*universePtr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.Select(strings.Split(*groupsPtr, ","))
+}
+
+// refreshData downloads new data for all groups, which replaces the data used by all handlers,
+// and logs the trade signals that changed with the new data.
+func refreshData(ctx context.Context) {
+	lp(logh.Info, "Scheduled data refresh starting")
+	for _, sg := range serverGroups {
+		if ctx.Err() != nil {
+			return
+		}
+		prior, priorOK := dataCache.Get(sg.symbols.Name)
+		job, _ := startDownload(sg)
+		if status, err := downloadJobs.Wait(ctx, job.ID); err != nil || status.State != jobs.Succeeded {
+			lpf(logh.Error, "Scheduled data refresh failed; group: %s, the prior data is still used", sg.symbols.Name)
+			continue
+		}
+		current, _ := dataCache.Get(sg.symbols.Name)
+		if !priorOK {
+			continue
+		}
+
+		priorSignals := latestSignals(sg, prior.Group)
+		changes := 0
+		currentSignals := latestSignals(sg, current.Group)
+		for _, strategy := range []string{"CvO", "MAH", "MA2"} {
+			for _, symbol := range sg.symbols.TradingSymbols() {
+				signal, ok := currentSignals[strategy][symbol]
+				priorSignal, priorOK := priorSignals[strategy][symbol]
+				if ok && priorOK && signal != priorSignal {
+					changes++
+					lpf(logh.Warning, "Signal change; group: %s, strategy: %s, symbol: %s, %s -> %s", sg.symbols.Name,
+						strategy, symbol, quant.TradeName(priorSignal), quant.TradeName(signal))
+				}
+			}
+		}
+		lpf(logh.Info, "Scheduled data refresh complete; group: %s, data version: %d, signal changes: %d",
+			sg.symbols.Name, current.Version, changes)
+	}
+}
+
 // runMARange can be used to run a range of inputs in order to see parameter sensitivity.
-func runMARange(tradingSymbols []string) {
-	dlGroup := <-dlGroupChanMA
+func runMARange(sg *serverGroup) {
+	tradingSymbols := sg.symbols.TradingSymbols()
+	entry, _ := dataCache.Get(sg.symbols.Name)
+	dlGroup := entry.Group
 	// maLength := []int{50, 60, 70, 80, 90, 100, 120, 140, 150, 160, 180, 200, 220, 240, 260, 280, 300, 350, 400, 450, 500, 600, 700, 800, 900, 1000, 1200}
 	// maSplit := []float64{0.04, 0.05, 0.06, 0.07, 0.08, 0.09, 0.10, 0.12, 0.14, 0.16, 0.18, 0.20, 0.22}
 	// for MA2
@@ -213,41 +552,231 @@ func runMARange(tradingSymbols []string) {
 		results[i] = make([]string, len(maSplit))
 		for j := range maSplit {
 			symbolResults := 1.0
+			// sharpe and turnover are the mean of the symbols, and maxDrawdown the largest.
+			var sharpe, maxDrawdown, turnover float64
+			symbols := 0
 			// qg := quantCvO.GetGroup(dlGroup, tradingSymbols, maLength[i], maSplit[j])
 			// qg := quantMAH.GetGroup(dlGroup, tradingSymbols, maLength[i], maSplit[j], defs.MAHShortShiftDefault, defs.MAHStopLoss, defs.MAHStopLossDelay, defs.MAHLongQuickBuy, defs.MAHEMA)
 			qg := quantMA2.GetGroup(dlGroup, tradingSymbols, maLength[i], maSplit[j], defs.MA2ShortShiftDefault, defs.MA2StopLoss, defs.MA2StopLossDelay, defs.MA2LongQuickBuy, defs.MA2EMA)
 			for _, iss := range qg.Issues {
-				symbolResults *= iss.QuantsetAsColumns.Results.AnnualizedGain
+				symbolResults *= iss.QuantsetAsColumns.Results.AfterTaxAnnualizedGain
+				if iss.DownloaderIssue == nil {
+					continue
+				}
+				m := iss.QuantsetAsColumns.Metrics
+				sharpe += m.Sharpe
+				maxDrawdown = math.Max(maxDrawdown, m.MaxDrawdown)
+				turnover += m.Turnover
+				symbols++
+			}
+			if symbols > 0 {
+				sharpe /= float64(symbols)
+				turnover /= float64(symbols)
 			}
 			splitResults[j] = symbolResults
-			results[i][j] = fmt.Sprintf("%5.3f", symbolResults)
+			results[i][j] = fmt.Sprintf("%5.3f (Sharpe: %5.2f, max drawdown: %5.2f%%, turnover: %5.2f)", symbolResults, sharpe, maxDrawdown, turnover)
 		}
 	}
 
-	lpf(logh.Info, "runMARange output result is product of all symbol AnnualizedGain values")
+	lpf(logh.Info, "runMARange output result is product of all symbol AfterTaxAnnualizedGain values (in -baseCurrency when set; pre-tax without -tax), "+
+		"with the mean Sharpe ratio, largest max drawdown, and mean turnover (trades per year) of the symbols")
 	lpf(logh.Info, fmt.Sprintf("maSplit: %+v\n", maSplit))
 	for i := range results {
 		lpf(logh.Info, "maLength: %d %+v\n", maLength[i], results[i])
 	}
 }
 
-func wrappedDownloadYahooData(dataFilepath string, tradingSymbols []string,
-	dlGroupChanCvO chan *downloader.Group, dlGroupChanMA chan *downloader.Group,
-	dlGroupChanMA2 chan *downloader.Group) http.HandlerFunc {
+// serverGroupNamed returns the serverGroup named name, or nil.
+func serverGroupNamed(name string) *serverGroup {
+	for _, sg := range serverGroups {
+		if strings.EqualFold(sg.symbols.Name, name) {
+			return sg
+		}
+	}
+	return nil
+}
+
+// serverGroupWithSymbol returns the first serverGroup trading symbol, or nil.
+func serverGroupWithSymbol(symbol string) *serverGroup {
+	for _, sg := range serverGroups {
+		if slices.Contains(sg.symbols.TradingSymbols(), strings.ToLower(symbol)) {
+			return sg
+		}
+	}
+	return nil
+}
+
+// startDownload starts a job downloading the live data for sg, or returns the running job for sg.
+func startDownload(sg *serverGroup) (jobs.Status, bool) {
+	return downloadJobs.Start(sg.symbols.Name, sg.symbols.AllSymbols(), func(ctx context.Context, progress func(item string, err error)) error {
+		return downloadData(downloader.WithProgress(ctx, progress), true, sg)
+	})
+}
+
+// strategyCosts returns the trading costs of a strategy: spec, or the costs flag when spec is
+// blank; nil when both are blank.
+func strategyCosts(spec string) (quant.CostModel, error) {
+	if spec == "" {
+		spec = *costsPtr
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	return quant.ParseCosts(spec, quant.CostsDefault)
+}
+
+// wrappedDownloadData starts a job downloading the group in the group parameter, or a job for
+// each group when there is no group parameter, and replies with the jobs.Status of the jobs.
+// A group that is already downloading replies with the running job. The download runs after
+// the reply; poll /jobs/{id} for progress.
+func wrappedDownloadData() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := downloadYahooData(true, dataFilepath, tradingSymbols, dlGroupChanCvO, dlGroupChanMA, dlGroupChanMA2)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		groups := serverGroups
+		if name := r.URL.Query().Get("group"); name != "" {
+			sg := serverGroupNamed(name)
+			if sg == nil {
+				lpf(logh.Warning, "Group %s was not found.", name)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			groups = []*serverGroup{sg}
+		}
+		reply := make([]jobs.Status, len(groups))
+		for i, sg := range groups {
+			var started bool
+			reply[i], started = startDownload(sg)
+			if started {
+				lpf(logh.Info, "Download job started; job: %s, group: %s", reply[i].ID, sg.symbols.Name)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// wrappedGroupHandler calls the handler, from handlers, of the group in the group parameter.
+// When there is no group parameter the first group trading the symbol in the symbol parameter
+// is used, so requests made before groups were supported still work.
+func wrappedGroupHandler(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sg *serverGroup
+		if name := r.URL.Query().Get("group"); name != "" {
+			sg = serverGroupNamed(name)
+		} else {
+			sg = serverGroupWithSymbol(r.URL.Query().Get("symbol"))
+		}
+		if sg == nil {
+			lpf(logh.Warning, "No group for request: %s", r.URL.RawQuery)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		handlers[sg.symbols.Name](w, r)
+	}
+}
+
+// wrappedGroups replies with the name and description of the loaded groups.
+func wrappedGroups(groups []universe.Group) http.HandlerFunc {
+	type groupReply struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	reply := make([]groupReply, len(groups))
+	for i, group := range groups {
+		reply[i] = groupReply{Name: group.Name, Description: group.Description}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// wrappedJobs serves the download jobs: GET /jobs replies with the jobs.Status of all jobs,
+// GET /jobs/{id} with the jobs.Status of one job, and DELETE /jobs/{id} cancels a running job.
+func wrappedJobs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+		var reply interface{}
+		var err error
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			reply = downloadJobs.List()
+		case id != "" && r.Method == http.MethodGet:
+			reply, err = downloadJobs.Get(id)
+		case id != "" && r.Method == http.MethodDelete:
+			if err = downloadJobs.Cancel(id); err == nil {
+				lpf(logh.Info, "Download job canceled; job: %s", id)
+				reply, err = downloadJobs.Get(id)
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, jobs.ErrNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case err != nil:
+			lpf(logh.Warning, "%+v", err)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// wrappedProfiles replies with the downloader.Profile of every symbol of the group in the group
+// parameter (or the first group when there is no group parameter), keyed by symbol.
+func wrappedProfiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sg := serverGroups[0]
+		if name := r.URL.Query().Get("group"); name != "" {
+			sg = serverGroupNamed(name)
+		}
+		var entry downloader.CacheEntry
+		ok := sg != nil
+		if ok {
+			entry, ok = dataCache.Get(sg.symbols.Name)
+		}
+		if !ok {
+			lpf(logh.Warning, "No data for request: %s", r.URL.RawQuery)
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		reply := make(map[string]*downloader.Profile)
+		for _, iss := range entry.Group.Issues {
+			reply[iss.Symbol] = iss.Profile
+		}
 		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(reply); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
 
-func wrappedSymbols(tradingSymbols []string) http.HandlerFunc {
+// wrappedSymbols replies with the trading symbols of the group in the group parameter, or the
+// trading symbols of all groups when there is no group parameter.
+func wrappedSymbols(groups []universe.Group) http.HandlerFunc {
+	tradingSymbols := universe.Merge("", groups).TradingSymbols()
 	return func(w http.ResponseWriter, r *http.Request) {
+		symbols := tradingSymbols
+		if name := r.URL.Query().Get("group"); name != "" {
+			group, err := universe.Universe{Groups: groups}.Group(name)
+			if err != nil {
+				lpf(logh.Warning, "%+v", err)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			symbols = group.TradingSymbols()
+		}
 		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(tradingSymbols); err != nil {
+		if err := json.NewEncoder(w).Encode(symbols); err != nil {
 			log.Fatal(err)
 		}
 	}
@@ -0,0 +1,67 @@
+package quant
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/downloader"
+)
+
+// CashRate is the annual return of cash, in percent, while no trade is open; used for Issues
+// without Cash (see downloader.Group.SetCash).
+var CashRate = 0.0
+
+// CashGain returns the gain of cash while no trade of trades (from TradeLedger) is open; from
+// point delay, and from the exit signal of each trade to the next trade signal. Cash earns the
+// return of dlIssue.Cash when set, else CashRate; the gain is 1 with neither. Cash is held in
+// the base currency when dlIssue was converted to a base currency.
+func CashGain(delay int, trades []Trade, dlIssue downloader.Issue) float64 {
+	dates := dlIssue.DatasetAsColumns.Date
+	gain := 1.0
+	from := delay - 1
+	for _, t := range trades {
+		gain *= cashGain(dlIssue, from, dateIndex(dates, t.SignalDate))
+		from = -1
+		if t.ExitReason == ExitSignal || t.ExitReason == ExitStop {
+			from = dateIndex(dates, t.ExitSignalDate)
+		}
+	}
+	if from >= 0 {
+		gain *= cashGain(dlIssue, from, len(dates)-1)
+	}
+	return gain
+}
+
+// cashGain returns the product of the cashReturn of the points after from, through to.
+func cashGain(dlIssue downloader.Issue, from int, to int) float64 {
+	gain := 1.0
+	for i := from + 1; from >= 0 && i <= to; i++ {
+		gain *= cashReturn(dlIssue, i)
+	}
+	return gain
+}
+
+// cashReturn returns the return of cash from point i-1 to point i of dlIssue.
+func cashReturn(dlIssue downloader.Issue, i int) float64 {
+	dates := dlIssue.DatasetAsColumns.Date
+	if i < 1 || i >= len(dates) {
+		return 1
+	}
+	gain := 1.0
+	switch {
+	case len(dlIssue.Cash) == len(dates):
+		gain = dlIssue.Cash[i] / dlIssue.Cash[i-1]
+	case CashRate != 0:
+		gain = math.Pow(1+CashRate/100, dates[i].Sub(dates[i-1]).Hours()/(24*365))
+	}
+	if math.IsNaN(gain) || math.IsInf(gain, 0) {
+		return 1
+	}
+	return gain
+}
+
+// dateIndex returns the index of date in dates, which are in Date order.
+func dateIndex(dates []time.Time, date time.Time) int {
+	return sort.Search(len(dates), func(i int) bool { return !dates[i].Before(date) })
+}
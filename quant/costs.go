@@ -0,0 +1,132 @@
+package quant
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/keyValue"
+)
+
+// CostModel is the cost of trading; each trade pays FillCost when opened and when closed.
+type CostModel interface {
+	// FillCost returns the cost of a buy or sell at price, filled on point i of dlIssue, as a
+	// fraction of price.
+	FillCost(dlIssue downloader.Issue, i int, price float64) float64
+}
+
+// Slippage is a model of slippage; the difference between the price at which a trade is
+// signaled to fill and the price it actually fills.
+type Slippage string
+
+const (
+	SlippageNone Slippage = "none"
+	// SlippageVolume is market impact: SlippageFactor * sqrt(shares traded / volume of the point).
+	SlippageVolume Slippage = "volume"
+	// SlippageVolatility is SlippageFactor * the range, (High-Low)/Close, of the point.
+	SlippageVolatility Slippage = "volatility"
+)
+
+var slippages = []Slippage{SlippageNone, SlippageVolume, SlippageVolatility}
+
+// Costs is a CostModel of commissions, bid-ask spread, and slippage.
+type Costs struct {
+	// TradeSize is the value, in the currency of the Issue, of each trade; used to convert
+	// commissions to a fraction of the trade, and for the number of shares traded.
+	TradeSize float64
+	// CommissionFixed is paid on each fill, and CommissionPerShare on each share of a fill.
+	CommissionFixed    float64
+	CommissionPerShare float64
+	// SpreadBps is the bid-ask spread in basis points; half of the spread is paid on each fill.
+	SpreadBps      float64
+	Slippage       Slippage
+	SlippageFactor float64
+}
+
+// CostsDefault is the base of the Costs returned by ParseCosts; it has no costs.
+var CostsDefault = Costs{TradeSize: 10000, Slippage: SlippageNone}
+
+// FillCost implements CostModel.
+func (c Costs) FillCost(dlIssue downloader.Issue, i int, price float64) float64 {
+	if price <= 0 || c.TradeSize <= 0 {
+		return 0
+	}
+	dac := dlIssue.DatasetAsColumns
+	// price is adjusted; shares are traded at the unadjusted price, when known.
+	shares := c.TradeSize / price
+	if i < len(dac.Open) && dac.Open[i] > 0 {
+		shares = c.TradeSize / dac.Open[i]
+	}
+	cost := (c.CommissionFixed+c.CommissionPerShare*shares)/c.TradeSize + c.SpreadBps/2/10000
+	switch c.Slippage {
+	case SlippageVolume:
+		if i < len(dac.Volume) && dac.Volume[i] > 0 {
+			cost += c.SlippageFactor * math.Sqrt(shares/dac.Volume[i])
+		}
+	case SlippageVolatility:
+		if i < len(dac.Close) && dac.Close[i] > 0 {
+			cost += c.SlippageFactor * (dac.High[i] - dac.Low[i]) / dac.Close[i]
+		}
+	}
+	return cost
+}
+
+// ParseCosts returns base, modified by spec: comma separated key=value pairs; I.E.
+// commission=1,spreadBps=5,slippage=volume,slippageFactor=0.1. Keys are tradeSize, commission,
+// perShare, spreadBps, slippage (none, volume, volatility), and slippageFactor.
+func ParseCosts(spec string, base Costs) (Costs, error) {
+	costs := base
+	err := keyValue.Parse(spec, func(key string, value string) error {
+		var err error
+		switch key {
+		case "tradesize":
+			costs.TradeSize, err = strconv.ParseFloat(value, 64)
+		case "commission":
+			costs.CommissionFixed, err = strconv.ParseFloat(value, 64)
+		case "pershare":
+			costs.CommissionPerShare, err = strconv.ParseFloat(value, 64)
+		case "spreadbps":
+			costs.SpreadBps, err = strconv.ParseFloat(value, 64)
+		case "slippage":
+			costs.Slippage, err = ParseSlippage(value)
+		case "slippagefactor":
+			costs.SlippageFactor, err = strconv.ParseFloat(value, 64)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		return err
+	})
+	if err != nil {
+		return base, fmt.Errorf("invalid cost: %w", err)
+	}
+	return costs, nil
+}
+
+// ParseSlippage converts the name of a Slippage.
+func ParseSlippage(name string) (Slippage, error) {
+	for _, slippage := range slippages {
+		if strings.EqualFold(string(slippage), strings.TrimSpace(name)) {
+			return slippage, nil
+		}
+	}
+	return "", fmt.Errorf("invalid slippage: %s, valid slippage: %v", name, slippages)
+}
+
+// fillCost returns the FillCost of costs; 0 when costs is nil.
+func fillCost(costs CostModel, dlIssue downloader.Issue, i int, price float64) float64 {
+	if costs == nil {
+		return 0
+	}
+	return costs.FillCost(dlIssue, i, price)
+}
+
+// costHistory returns the trading costs, in percent, for the trade history; empty when there
+// is no cost.
+func costHistory(cost float64) string {
+	if cost == 0 {
+		return ""
+	}
+	return fmt.Sprintf("costs: %5.2f%%, ", cost)
+}
@@ -0,0 +1,205 @@
+// Package metrics computes risk and performance metrics of a strategy from its gain curve
+// (quant.Results.TradeGainVsTime) and trade ledger (quant.Results.Trades).
+//
+// Metrics are in percent unless noted, and annualized using the number of points per year of
+// the Issue. When the Issue was converted to a base currency (see downloader.Issue.FXRate),
+// gains are in the base currency. Metrics that are undefined (I.E. the profit factor with no losing trades) are 0,
+// so Metrics can always be served as JSON.
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/quant"
+)
+
+// Metrics are the risk and performance metrics of a strategy on one Issue.
+type Metrics struct {
+	// CAGR and BuyHoldCAGR are the compound annual growth rate of the strategy, and of buying
+	// and holding the Issue, over the same points.
+	CAGR        float64
+	BuyHoldCAGR float64
+	// MaxDrawdown is the largest decline of the gain curve from a prior peak.
+	// MaxDrawdownBars and MaxDrawdownDays are the duration of the longest drawdown; from the
+	// peak until the gain curve recovers the peak, or the last point.
+	MaxDrawdown     float64
+	MaxDrawdownBars int
+	MaxDrawdownDays int
+	// Volatility is the annualized standard deviation of the returns of each point.
+	Volatility float64
+	// Sharpe, Sortino, and Calmar are ratios; not percent. Sortino uses the downside deviation,
+	// and Calmar is CAGR/MaxDrawdown.
+	Sharpe  float64
+	Sortino float64
+	Calmar  float64
+	// Trades is the number of closed trades; WinRate, ProfitFactor, AverageWin, and
+	// AverageLoss are of the closed trades. ProfitFactor is the sum of wins divided by the sum
+	// of losses, as a ratio.
+	Trades       int
+	WinRate      float64
+	ProfitFactor float64
+	AverageWin   float64
+	AverageLoss  float64
+	// Exposure is the percent of points with an open trade.
+	Exposure float64
+	// Turnover is the number of trades opened per year.
+	Turnover float64
+}
+
+// RiskFree is the annual risk free rate, in percent, used by Sharpe and Sortino.
+var RiskFree = 0.0
+
+// New computes the Metrics of results, a strategy run on dlIssue; the metrics start at point
+// delay, where the strategy starts trading.
+func New(delay int, results quant.Results, dlIssue downloader.Issue) Metrics {
+	var m Metrics
+	dac := dlIssue.DatasetAsColumns
+	gain := results.TradeGainVsTime
+	if delay < 0 || len(gain) < delay+2 || len(dac.Date) != len(gain) || len(results.Trade) != len(gain) {
+		return m
+	}
+	start, end := dac.Date[delay], dac.Date[len(dac.Date)-1]
+	years := end.Sub(start).Hours() / (24 * 365)
+	if years <= 0 {
+		return m
+	}
+	pointsPerYear := float64(len(gain)-1-delay) / years
+
+	bhGain := dac.AdjClose[len(dac.AdjClose)-1] / dac.AdjOpen[delay]
+	if fxRate := dlIssue.FXRate; dlIssue.BaseCurrency != "" && len(fxRate) == len(gain) {
+		gain = baseGainCurve(gain, results.Trade, fxRate)
+		bhGain *= fxRate[len(fxRate)-1] / fxRate[delay]
+	}
+	m.CAGR = percent(quant.AnnualizedGain(gain[len(gain)-1]/gain[delay], start, end))
+	m.BuyHoldCAGR = percent(quant.AnnualizedGain(bhGain, start, end))
+	m.drawdown(gain[delay:], dac.Date[delay:])
+	m.returns(gain[delay:], pointsPerYear)
+	if m.MaxDrawdown > 0 {
+		m.Calmar = m.CAGR / m.MaxDrawdown
+	}
+	m.trades(results.Trades)
+	m.Turnover = float64(len(results.Trades)) / years
+
+	open := 0
+	for _, trade := range results.Trade[delay:] {
+		if trade != quant.Close {
+			open++
+		}
+	}
+	m.Exposure = 100 * float64(open) / float64(len(results.Trade)-delay)
+
+	// Prices with missing (NaN) values, or too few points to annualize, make metrics undefined.
+	for _, v := range []*float64{&m.CAGR, &m.BuyHoldCAGR, &m.MaxDrawdown, &m.Volatility, &m.Sharpe, &m.Sortino,
+		&m.Calmar, &m.WinRate, &m.ProfitFactor, &m.AverageWin, &m.AverageLoss, &m.Exposure, &m.Turnover} {
+		if math.IsNaN(*v) || math.IsInf(*v, 0) {
+			*v = 0
+		}
+	}
+	return m
+}
+
+// drawdown sets the drawdown metrics of gain curve gain.
+func (m *Metrics) drawdown(gain []float64, dates []time.Time) {
+	peak, peakIndex := gain[0], 0
+	for i, g := range gain {
+		if g >= peak {
+			peak, peakIndex = g, i
+		}
+		if dd := 100 * (1 - g/peak); dd > m.MaxDrawdown {
+			m.MaxDrawdown = dd
+		}
+		if bars := i - peakIndex; g < peak && bars > m.MaxDrawdownBars {
+			m.MaxDrawdownBars = bars
+			m.MaxDrawdownDays = int(dates[i].Sub(dates[peakIndex]).Hours() / 24)
+		}
+	}
+}
+
+// returns sets the metrics of the returns of each point of gain curve gain.
+func (m *Metrics) returns(gain []float64, pointsPerYear float64) {
+	riskFree := math.Pow(1+RiskFree/100, 1/pointsPerYear) - 1
+	var sum, sumSquares, downside float64
+	n := float64(len(gain) - 1)
+	for i := 1; i < len(gain); i++ {
+		r := gain[i]/gain[i-1] - 1
+		sum += r
+		sumSquares += r * r
+		if r < riskFree {
+			downside += (r - riskFree) * (r - riskFree)
+		}
+	}
+	mean := sum / n
+	stdDev := math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+	downsideDev := math.Sqrt(downside / n)
+	m.Volatility = 100 * stdDev * math.Sqrt(pointsPerYear)
+	if stdDev > 0 {
+		m.Sharpe = (mean - riskFree) / stdDev * math.Sqrt(pointsPerYear)
+	}
+	if downsideDev > 0 {
+		m.Sortino = (mean - riskFree) / downsideDev * math.Sqrt(pointsPerYear)
+	}
+}
+
+// trades sets the metrics of the closed trades of ledger trades, using BaseGain when the Issue
+// was converted to a base currency.
+func (m *Metrics) trades(trades []quant.Trade) {
+	var wins, losses int
+	var sumWins, sumLosses float64
+	for _, t := range trades {
+		if t.ExitReason == "" || t.ExitReason == quant.ExitEndOfData {
+			continue
+		}
+		m.Trades++
+		gain := t.Gain
+		if t.BaseGain != 0 {
+			gain = t.BaseGain
+		}
+		switch r := gain - 1; {
+		case r > 0:
+			wins++
+			sumWins += r
+		case r < 0:
+			losses++
+			sumLosses -= r
+		}
+	}
+	if m.Trades > 0 {
+		m.WinRate = 100 * float64(wins) / float64(m.Trades)
+	}
+	if wins > 0 {
+		m.AverageWin = 100 * sumWins / float64(wins)
+	}
+	if losses > 0 {
+		m.AverageLoss = -100 * sumLosses / float64(losses)
+		m.ProfitFactor = sumWins / sumLosses
+	}
+}
+
+// baseGainCurve returns gain curve gain in base currency, converting the return of each point
+// with a trade open at fxRate; trades close at the open of the point after the Close signal.
+func baseGainCurve(gain []float64, trade []int, fxRate []float64) []float64 {
+	out := make([]float64, len(gain))
+	out[0] = gain[0]
+	for i := 1; i < len(gain); i++ {
+		r := gain[i] / gain[i-1]
+		if prior := trade[i-1]; prior != quant.Close {
+			fx := fxRate[i] / fxRate[i-1]
+			if trade[i] == quant.Close && i < len(fxRate)-1 {
+				fx = fxRate[i+1] / fxRate[i-1]
+			}
+			if prior <= quant.ShortSell {
+				fx = 1 / fx
+			}
+			r *= fx
+		}
+		out[i] = out[i-1] * r
+	}
+	return out
+}
+
+// percent converts annualized gain to percent; I.E. 1.07 is 7.
+func percent(annualizedGain float64) float64 {
+	return 100 * (annualizedGain - 1)
+}
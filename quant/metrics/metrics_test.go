@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/quant"
+)
+
+func ExampleNew() {
+	lby := quant.LongBuy
+	cls := quant.Close
+	sht := quant.ShortSell
+	trade____ := []int{cls, lby, lby, lby, lby, cls, cls, sht, sht, cls, lby, lby, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 11.0, 12.0, 10.0, 11.0, 11.0, 11.0, 10.0, 9.0, 9.0, 9.5, 8.5}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 12.0, 10.0, 11.0, 11.0, 11.0, 11.0, 9.0, 9.0, 9.0, 9.0}
+	for month := 0; month < 13; month++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(2021, time.Month(1+month), 1, 0, 0, 0, 0, time.UTC))
+	}
+	trades, gain, tradeGain := quant.TradeLedger(1, trade____, nil, nil, issue)
+	results := quant.Results{TotalGain: gain, Trade: trade____, TradeGainVsTime: tradeGain, Trades: trades}
+	m := New(1, results, issue)
+	fmt.Printf("CAGR: %.2f, buy/hold CAGR: %.2f, max drawdown: %.2f, bars: %d, days: %d\n",
+		m.CAGR, m.BuyHoldCAGR, m.MaxDrawdown, m.MaxDrawdownBars, m.MaxDrawdownDays)
+	fmt.Printf("volatility: %.2f, Sharpe: %.2f, Sortino: %.2f, Calmar: %.2f\n", m.Volatility, m.Sharpe, m.Sortino, m.Calmar)
+	fmt.Printf("trades: %d, win rate: %.2f, profit factor: %.2f, average win: %.2f, average loss: %.2f\n",
+		m.Trades, m.WinRate, m.ProfitFactor, m.AverageWin, m.AverageLoss)
+	fmt.Printf("exposure: %.2f, turnover: %.2f\n", m.Exposure, m.Turnover)
+	fmt.Printf("%+v\n", New(1, quant.Results{}, issue))
+
+	// Output:
+	// CAGR: 29.82, buy/hold CAGR: -16.27, max drawdown: 16.67, bars: 4, days: 122
+	// volatility: 30.50, Sharpe: 1.02, Sortino: 1.51, Calmar: 1.79
+	// trades: 3, win rate: 66.67, profit factor: 5.80, average win: 16.11, average loss: -5.56
+	// exposure: 66.67, turnover: 3.28
+	// {CAGR:0 BuyHoldCAGR:0 MaxDrawdown:0 MaxDrawdownBars:0 MaxDrawdownDays:0 Volatility:0 Sharpe:0 Sortino:0 Calmar:0 Trades:0 WinRate:0 ProfitFactor:0 AverageWin:0 AverageLoss:0 Exposure:0 Turnover:0}
+}
+
+func ExampleNew_baseCurrency() {
+	lby := quant.LongBuy
+	cls := quant.Close
+	trade____ := []int{cls, lby, lby, lby, cls, cls}
+	issue := downloader.Issue{Symbol: "test", BaseCurrency: "USD"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.FXRate = []float64{1.0, 1.0, 1.0, 1.5, 2.0, 2.0}
+	for y := 2018; y <= 2023; y++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	// The price is unchanged; the gains are the change in the value of the currency.
+	trades, gain, tradeGain := quant.TradeLedger(1, trade____, nil, nil, issue)
+	results := quant.Results{TotalGain: gain, Trade: trade____, TradeGainVsTime: tradeGain, Trades: trades}
+	m := New(1, results, issue)
+	fmt.Printf("CAGR: %.2f, buy/hold CAGR: %.2f, max drawdown: %.2f\n", m.CAGR, m.BuyHoldCAGR, m.MaxDrawdown)
+	fmt.Printf("trades: %d, win rate: %.2f, average win: %.2f\n", m.Trades, m.WinRate, m.AverageWin)
+
+	// Output:
+	// CAGR: 18.91, buy/hold CAGR: 18.91, max drawdown: 0.00
+	// trades: 1, win rate: 100.00, average win: 100.00
+}
@@ -3,6 +3,7 @@ package quant
 import (
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/paulfdunn/go-helper/logh/v2"
@@ -15,8 +16,65 @@ type Results struct {
 	TradeHistory    string
 	Trade           []int
 	TradeGainVsTime []float64
+	// BaseGain and BaseAnnualizedGain are TotalGain and AnnualizedGain in the base currency of an
+	// Issue converted to a base currency; otherwise they are the same as the local currency gains.
+	BaseGain           float64
+	BaseAnnualizedGain float64
+	// Trades is the ledger TradeHistory is rendered from.
+	Trades []Trade
+	// AfterTaxGain and AfterTaxAnnualizedGain are BaseGain and BaseAnnualizedGain after tax (see
+	// Tax); the same as the pre-tax gains when Tax is nil.
+	AfterTaxGain           float64
+	AfterTaxAnnualizedGain float64
 }
 
+// Trade is a trade in the ledger returned by TradeLedger. Prices are adjusted prices; gains are
+// exit/entry price for long trades, and entry/exit price for short trades.
+type Trade struct {
+	Symbol string
+	// Direction is LongBuy or ShortSell; Entry is the trade signal that opened the trade:
+	// LongBuy, LongQuickBuy, or ShortSell.
+	Direction int
+	Entry     int
+	// SignalDate is the date of the signal that opened the trade; the trade is made at the open
+	// of EntryDate, the next point. EntryPending is true when the signal is on the last point, so
+	// the trade is made on the next trading day.
+	SignalDate   time.Time
+	EntryDate    time.Time
+	EntryPrice   float64
+	EntryPending bool
+	// ExitReason is empty while the trade has not been closed or priced.
+	ExitReason     ExitReason
+	ExitSignalDate time.Time
+	ExitDate       time.Time
+	ExitPrice      float64
+	ExitPending    bool
+	// HoldingDays is the number of calendar days from EntryDate to ExitDate.
+	HoldingDays int
+	// Dividends are received (long) or paid (short) per share; HoldingCost is the percent of a
+	// long position paid as holding cost, and Costs the percent of the gain paid as trading
+	// costs (see CostModel). Gain includes both.
+	Dividends   float64
+	HoldingCost float64
+	Costs       float64
+	Gain        float64
+	// BaseGain is the gain in base currency; 0 when the Issue was not converted.
+	BaseGain float64
+	// Tax is the percent of the trade value paid as tax (negative for losses), using Tax; trades
+	// still open are taxed as if sold at the last close. AfterTaxGain is in base currency when
+	// BaseGain is not 0. LongTerm and WashSale are as defined by TaxRates.
+	LongTerm     bool
+	WashSale     bool
+	Tax          float64
+	AfterTaxGain float64
+
+	// entryCost is the fraction of EntryPrice paid as trading costs.
+	entryCost float64
+}
+
+// ExitReason is the reason a Trade was closed.
+type ExitReason string
+
 type TradeOnSignalLongQuickBuyInputs struct {
 	DlIssue              *downloader.Issue
 	AllowedLongQuickBuys int
@@ -32,6 +90,11 @@ const (
 	Close        = 0
 	ShortSell    = -1
 
+	ExitSignal ExitReason = "signal"
+	ExitStop   ExitReason = "stop"
+	// ExitEndOfData trades are still open; they are priced at the last close.
+	ExitEndOfData ExitReason = "end of data"
+
 	// TradeGap is the minimum number of points between trades. Settlement time is 1 days
 	// so 1 is used to insure another trade is not opened until the previous one is settled.
 	TradeGap = 1
@@ -44,6 +107,11 @@ var (
 
 	// stopLoss      = 0.9
 	// stopLossDelay = 15
+
+	// HoldingCost, when true, charges long trades the annual Profile.ExpenseRatio of the Issue,
+	// pro rata for the time the trade is open. Short trades are not charged. This models an
+	// extra cost; adjusted prices of ETFs are already net of the expense ratio.
+	HoldingCost = false
 )
 
 func Init(appNameInit string) {
@@ -339,23 +407,92 @@ func SumSlices(dataSlices ...[]float64) ([]float64, error) {
 	return out, nil
 }
 
+// TotalReturn returns the accumulated total return of dlIssue at each point, starting at 1,
+// computed from Close and the dividends paid on each ex-dividend date, rather than from AdjClose.
+// Dividends are paid on the first point on or after the ex-dividend date, so weekly and monthly
+// points receive the dividends of the whole period. Close must be split adjusted.
+func TotalReturn(dlIssue downloader.Issue) []float64 {
+	closePrice := dlIssue.DatasetAsColumns.Close
+	if len(closePrice) == 0 {
+		return nil
+	}
+	dividends := dividendsByDate(dlIssue)
+	out := make([]float64, len(closePrice))
+	out[0] = 1
+	for i := 1; i < len(closePrice); i++ {
+		out[i] = out[i-1] * (closePrice[i] + dividendOn(dividends, dlIssue.DatasetAsColumns.Date, i)) / closePrice[i-1]
+	}
+	return out
+}
+
 // TradeGain takes in input slice trade with values [LongQuickBuy, LongBuy, Close, ShortSell], and after delay number of points,
 // applies the [LongQuickBuy, LongBuy, Close, ShortSell] signals to dlIssue to proces a tradeHistory, gain (total gain), and
 // tradeGain (accumulated gain/loss at each point).
 // All trades MUST Close between either a LongBuy or a ShortSell.
+// Dividends with an ex-dividend date after the entry fill date, through the exit fill date, are
+// shown in tradeHistory as cash received (long) or paid (short) per share; a position bought at
+// the open of an ex-dividend date does not receive that dividend, and a position sold at the
+// open of an ex-dividend date does. Gains already include dividends as they use adjusted prices.
+// tradeHistory is rendered from the ledger returned by TradeLedger; see TradeHistory.
 func TradeGain(delay int, trade []int, dlIssue downloader.Issue) (tradeHistory string, gain float64, tradeGain []float64) {
-	seriesLen := len(dlIssue.DatasetAsColumns.AdjOpen)
+	tradeHistory, gain, _, tradeGain = TradeGainBase(delay, trade, dlIssue)
+	return tradeHistory, gain, tradeGain
+}
+
+// TradeGainBase is TradeGain, also returning baseGain, the total gain in the base currency of
+// dlIssue; baseGain is gain when dlIssue was not converted to a base currency.
+func TradeGainBase(delay int, trade []int, dlIssue downloader.Issue) (tradeHistory string, gain float64, baseGain float64, tradeGain []float64) {
+	trades, gain, tradeGain := TradeLedger(delay, trade, nil, nil, dlIssue)
+	return TradeHistory(delay, trades, dlIssue), gain, LedgerBaseGain(delay, trades, gain, dlIssue), tradeGain
+}
+
+// LedgerBaseGain returns the total gain of trades (from TradeLedger) in the base currency of
+// dlIssue, with the CashGain; gain, the total gain returned by TradeLedger, when dlIssue was not
+// converted to a base currency.
+func LedgerBaseGain(delay int, trades []Trade, gain float64, dlIssue downloader.Issue) float64 {
+	if baseFXRate(dlIssue) == nil {
+		return gain
+	}
+	baseGain := CashGain(delay, trades, dlIssue)
+	for _, t := range trades {
+		if t.ExitReason != "" {
+			baseGain *= t.BaseGain
+		}
+	}
+	return baseGain
+}
+
+// TradeLedger applies trade to dlIssue, as TradeGain, and returns the trades as a ledger, in
+// Date order, with gain and tradeGain as returned by TradeGain. stopExit (see
+// TradeAddStopExits) marks the points where a stop closed a trade; nil when there are no stops.
+// LongQuickBuy trades closed without becoming a LongBuy were closed by the quick buy stop.
+// Each fill pays the trading costs of costs; nil for no costs. Trades still open at the end of
+// the data have not paid the cost of closing. While no trade is open, gains include the return
+// of cash; see CashGain.
+func TradeLedger(delay int, trade []int, stopExit []bool, costs CostModel, dlIssue downloader.Issue) (trades []Trade, gain float64, tradeGain []float64) {
+	dac := dlIssue.DatasetAsColumns
+	seriesLen := len(dac.AdjOpen)
 	// tradeGain is the product of all daily changes in Issue price while a trades are open. This is useful
 	// for graphing the progression of gains.
 	tradeGain = make([]float64, seriesLen)
 	// gain is the product of all trade gains; where gain is sale_price/purchase_price.
 	// This will be slightly different than tradeGain due to floating point errors.
 	gain = 1.0
-	var longBuyPrice, shortSellPrice float64
-	fd := dlIssue.DatasetAsColumns.Date[0].Format(DateFormat)
-	ld := dlIssue.DatasetAsColumns.Date[len(dlIssue.DatasetAsColumns.Date)-1].Format(DateFormat)
-	tradeHistory = fmt.Sprintf("first trading day: %s, last trading day: %s\n", fd, ld)
-	lpf(logh.Info, "%s", tradeHistory)
+	dividends := dividendsByDate(dlIssue)
+	// When the Issue was converted to a base currency, gains are also reported in base currency;
+	// openFX is the FX rate when the open trade was opened.
+	fxRate := baseFXRate(dlIssue)
+	openFX := math.NaN()
+	// t is the open trade.
+	var t *Trade
+	// nextDividend returns the dividends with an ex-dividend date on the bar after i; a position
+	// held at the close of bar i receives them.
+	nextDividend := func(i int) float64 {
+		if i >= seriesLen-1 {
+			return 0
+		}
+		return dividendOn(dividends, dac.Date, i+1)
+	}
 	for i := 0; i < seriesLen; i++ {
 		if i <= delay-1 {
 			tradeGain[i] = 1
@@ -364,92 +501,192 @@ func TradeGain(delay int, trade []int, dlIssue downloader.Issue) (tradeHistory s
 
 		switch {
 		case trade[i-1] == Close && (trade[i] >= LongBuy || trade[i] <= ShortSell):
-			action := ""
-			var price float64
+			openIndex := i
 			if i < seriesLen-1 {
-				price = dlIssue.DatasetAsColumns.AdjOpen[i+1]
-			} else {
-				price = dlIssue.DatasetAsColumns.AdjOpen[i]
+				openIndex = i + 1
 			}
-			if trade[i] >= LongBuy {
-				action = "long buy"
-				longBuyPrice = price
-			} else {
-				action = "short sell"
-				shortSellPrice = price
+			if fxRate != nil {
+				openFX = fxRate[openIndex]
 			}
-			tradeGain[i] = tradeGain[i-1]
-			if i == seriesLen-1 {
-				action = fmt.Sprintf("**** %s TOMORROW ****", action)
+			trades = append(trades, Trade{Symbol: dlIssue.Symbol, Direction: LongBuy, Entry: trade[i],
+				SignalDate: dac.Date[i], EntryDate: dac.Date[openIndex], EntryPrice: dac.AdjOpen[openIndex],
+				EntryPending: i == seriesLen-1})
+			t = &trades[len(trades)-1]
+			t.entryCost = fillCost(costs, dlIssue, openIndex, t.EntryPrice)
+			if trade[i] <= ShortSell {
+				t.Direction = ShortSell
+				tradeGain[i] = tradeGain[i-1] * cashReturn(dlIssue, i) * (1 - t.entryCost)
+			} else {
+				tradeGain[i] = tradeGain[i-1] * cashReturn(dlIssue, i) / (1 + t.entryCost)
 			}
-			tradeHistory += fmt.Sprintf("symbol: %s, date: %s, %s price: %8.2f, ",
-				dlIssue.Symbol, dlIssue.DatasetAsColumns.Date[i].Format(DateFormat), action, price)
 		case (trade[i-1] >= LongBuy && trade[i] >= LongBuy) || (trade[i-1] <= ShortSell && trade[i] <= ShortSell):
-			action := ""
-			var price, pointGain, thisGain float64
-			pointGain = dlIssue.DatasetAsColumns.AdjClose[i] / dlIssue.DatasetAsColumns.AdjClose[i-1]
+			pointGain := dac.AdjClose[i] / dac.AdjClose[i-1]
 			if trade[i] >= LongBuy {
-				action = "long sell"
-				price = longBuyPrice
-				thisGain = dlIssue.DatasetAsColumns.AdjClose[i] / price
+				pointGain *= pointHoldingCost(dlIssue, t.EntryDate, dac.Date[i-1], dac.Date[i])
 			} else {
-				action = "short buy"
-				price = shortSellPrice
 				pointGain = 1 / pointGain
-				thisGain = price / dlIssue.DatasetAsColumns.AdjClose[i]
 			}
 			tradeGain[i] = tradeGain[i-1] * pointGain
+			t.Dividends += nextDividend(i)
 			if i == seriesLen-1 {
-				tradeHistory += fmt.Sprintf("date: %s, ", dlIssue.DatasetAsColumns.Date[i].Format(DateFormat))
-				gain *= thisGain
-				tradeHistory += fmt.Sprintf("%s price: %8.2f, gain: %8.2f (TRADE STILL OPEN)\n", action, dlIssue.DatasetAsColumns.AdjClose[i], thisGain)
+				// The trade is still open; the gain is at the last close.
+				t.ExitSignalDate, t.ExitDate, t.ExitPrice, t.ExitReason = dac.Date[i], dac.Date[i], dac.AdjClose[i], ExitEndOfData
+				t.close(dlIssue, fxRate, openFX, i, 0)
+				gain *= t.Gain
 			}
 		case (trade[i-1] >= LongBuy || trade[i-1] <= ShortSell) && trade[i] == Close:
-			action := ""
-			var finalGain, pointGain, price, thisGain float64
-			pointGain = dlIssue.DatasetAsColumns.AdjClose[i] / dlIssue.DatasetAsColumns.AdjClose[i-1]
+			var finalGain float64
+			pointGain := dac.AdjClose[i] / dac.AdjClose[i-1]
+			t.Dividends += nextDividend(i)
+			closeIndex := i
 			if i < seriesLen-1 {
-				price = dlIssue.DatasetAsColumns.AdjOpen[i+1]
-				finalGain = dlIssue.DatasetAsColumns.AdjOpen[i+1] / dlIssue.DatasetAsColumns.AdjClose[i]
+				closeIndex = i + 1
+				t.ExitPrice = dac.AdjOpen[i+1]
+				finalGain = dac.AdjOpen[i+1] / dac.AdjClose[i]
 			} else {
-				price = dlIssue.DatasetAsColumns.AdjClose[i]
+				t.ExitPrice = dac.AdjClose[i]
 				// There is no final gain to calculate, trade is closing at the final point.
 				finalGain = 1.0
 			}
+			exitCost := fillCost(costs, dlIssue, closeIndex, t.ExitPrice)
 			if trade[i-1] >= LongBuy {
-				action = "long sell"
-				thisGain = price / longBuyPrice
-				// Protect against logic errors by setting longBuyPrice to NaN when not in use.
-				longBuyPrice = math.NaN()
-				tradeGain[i] = tradeGain[i-1] * pointGain * finalGain
+				tradeGain[i] = tradeGain[i-1] * pointGain * finalGain * (1 - exitCost) *
+					pointHoldingCost(dlIssue, t.EntryDate, dac.Date[i-1], dac.Date[closeIndex])
 			} else {
-				action = "short buy"
-				thisGain = shortSellPrice / price
-				// Protect against logic errors by setting shortSellPrice to NaN when not in use.
-				shortSellPrice = math.NaN()
-				tradeGain[i] = tradeGain[i-1] * (1 / pointGain) * (1 / finalGain)
+				tradeGain[i] = tradeGain[i-1] * (1 / pointGain) * (1 / finalGain) / (1 + exitCost)
 			}
-			if i == seriesLen-1 {
-				action = fmt.Sprintf("**** %s TOMORROW ****", action)
+			t.ExitSignalDate, t.ExitDate, t.ExitPending = dac.Date[i], dac.Date[closeIndex], i == seriesLen-1
+			t.ExitReason = ExitSignal
+			if (stopExit != nil && stopExit[i]) || trade[i-1] == LongQuickBuy {
+				t.ExitReason = ExitStop
 			}
-			tradeHistory += fmt.Sprintf("date: %s, ", dlIssue.DatasetAsColumns.Date[i].Format(DateFormat))
-			gain *= thisGain
-			tradeHistory += fmt.Sprintf("%s price: %8.2f, gain: %8.2f\n", action, price, thisGain)
+			t.close(dlIssue, fxRate, openFX, closeIndex, exitCost)
+			gain *= t.Gain
+			openFX = math.NaN()
+			t = nil
 		case trade[i-1] == Close && trade[i] == Close:
-			tradeGain[i] = tradeGain[i-1]
+			tradeGain[i] = tradeGain[i-1] * cashReturn(dlIssue, i)
 		}
 	}
+	gain *= CashGain(delay, trades, dlIssue)
+	applyTax(Tax, trades)
+	return trades, gain, tradeGain
+}
+
+// TradeHistory renders trades (from TradeLedger) as the trade history of TradeGain: a line for
+// each trade, then the buy/hold and total gains. The history is also logged.
+func TradeHistory(delay int, trades []Trade, dlIssue downloader.Issue) string {
+	dac := dlIssue.DatasetAsColumns
+	seriesLen := len(dac.Date)
+	dateFormat := dlIssue.Interval.DateFormat()
+	fd := dac.Date[0].Format(dateFormat)
+	ld := dac.Date[seriesLen-1].Format(dateFormat)
+	tradeHistory := fmt.Sprintf("first trading day: %s, last trading day: %s\n", fd, ld)
+	lpf(logh.Info, "%s", tradeHistory)
 
-	start := dlIssue.DatasetAsColumns.Date[delay]
-	end := dlIssue.DatasetAsColumns.Date[seriesLen-1]
-	bhGain := dlIssue.DatasetAsColumns.AdjClose[seriesLen-1] / dlIssue.DatasetAsColumns.AdjOpen[delay]
+	// costGain is the gain before trading costs.
+	gain, baseGain, costGain := 1.0, 1.0, 1.0
+	for _, t := range trades {
+		action := "long buy"
+		if t.Direction <= ShortSell {
+			action = "short sell"
+		}
+		if t.EntryPending {
+			action = fmt.Sprintf("**** %s %s ****", action, nextTradingDay(dlIssue))
+		}
+		tradeHistory += fmt.Sprintf("symbol: %s, date: %s, %s price: %8.2f, ",
+			t.Symbol, t.SignalDate.Format(dateFormat), action, t.EntryPrice)
+		if t.ExitReason == "" {
+			continue
+		}
+
+		action = "long sell"
+		if t.Direction <= ShortSell {
+			action = "short buy"
+		}
+		if t.ExitPending {
+			action = fmt.Sprintf("**** %s %s ****", action, nextTradingDay(dlIssue))
+		}
+		gain *= t.Gain
+		costGain *= t.Gain / (1 - t.Costs/100)
+		baseHistory := ""
+		if t.BaseGain != 0 {
+			baseGain *= t.BaseGain
+			baseHistory = fmt.Sprintf(", gain (%s): %8.2f", dlIssue.BaseCurrency, t.BaseGain)
+		}
+		stillOpen := ""
+		if t.ExitReason == ExitEndOfData {
+			stillOpen = " (TRADE STILL OPEN)"
+		}
+		tradeHistory += fmt.Sprintf("date: %s, %s price: %8.2f, %s%s%sgain: %8.2f%s%s%s\n", t.ExitSignalDate.Format(dateFormat),
+			action, t.ExitPrice, dividendHistory(t.Dividends, t.Direction), holdingCostHistory(t.HoldingCost), costHistory(t.Costs),
+			t.Gain, baseHistory, taxHistory(Tax, t), stillOpen)
+	}
+
+	start := dac.Date[delay]
+	end := dac.Date[seriesLen-1]
+	bhGain := dac.AdjClose[seriesLen-1] / dac.AdjOpen[delay]
 	tradeHistory += fmt.Sprintf("symbol: %s, buy/hold gain (annualized): %5.2f (%5.2f)\n",
 		dlIssue.Symbol, bhGain, AnnualizedGain(bhGain, start, end))
+	if CashRate != 0 || len(dlIssue.Cash) == seriesLen {
+		// gain and costGain are of the trades; the total gains include cash.
+		cashGain := CashGain(delay, trades, dlIssue)
+		gain *= cashGain
+		baseGain *= cashGain
+		costGain *= cashGain
+		tradeHistory += fmt.Sprintf("symbol: %s, cash gain while no trade is open (annualized): %5.2f (%5.2f)\n",
+			dlIssue.Symbol, cashGain, AnnualizedGain(cashGain, start, end))
+	}
+	if len(dlIssue.Dividends) > 0 {
+		totalReturn := TotalReturn(dlIssue)
+		trGain := totalReturn[seriesLen-1] / totalReturn[delay]
+		tradeHistory += fmt.Sprintf("symbol: %s, buy/hold total return (annualized): %5.2f (%5.2f), dividends: %d\n",
+			dlIssue.Symbol, trGain, AnnualizedGain(trGain, start, end), len(dlIssue.Dividends))
+	}
+	// bhTaxGain and taxCurrency are the buy/hold gain and currency of the after-tax gains.
+	bhTaxGain, taxCurrency := bhGain, ""
+	if fxRate := baseFXRate(dlIssue); fxRate != nil {
+		bhBaseGain := bhGain * fxRate[seriesLen-1] / fxRate[delay]
+		bhTaxGain, taxCurrency = bhBaseGain, " "+dlIssue.BaseCurrency
+		tradeHistory += fmt.Sprintf("symbol: %s, buy/hold gain %s (annualized): %5.2f (%5.2f)\n",
+			dlIssue.Symbol, dlIssue.BaseCurrency, bhBaseGain, AnnualizedGain(bhBaseGain, start, end))
+		tradeHistory += fmt.Sprintf("symbol: %s, total gain %s (annualized):    %5.2f (%5.2f)\n",
+			dlIssue.Symbol, dlIssue.BaseCurrency, baseGain, AnnualizedGain(baseGain, start, end))
+	}
+	if costGain != gain {
+		tradeHistory += fmt.Sprintf("symbol: %s, total gain before costs (annualized):    %5.2f (%5.2f), costs: %5.2f%%\n",
+			dlIssue.Symbol, costGain, AnnualizedGain(costGain, start, end), 100*(1-gain/costGain))
+	}
+	if Tax != nil {
+		bhTaxRate := Tax.ShortTerm
+		if int(end.Sub(start).Hours()/24) > Tax.LongTermDays {
+			bhTaxRate = Tax.LongTerm
+		}
+		bhAfterTaxGain := bhTaxGain - bhTaxRate*(bhTaxGain-1)/100
+		afterTaxGain := AfterTaxGain(delay, trades, dlIssue)
+		tradeHistory += fmt.Sprintf("symbol: %s, buy/hold gain%s after tax (annualized): %5.2f (%5.2f)\n",
+			dlIssue.Symbol, taxCurrency, bhAfterTaxGain, AnnualizedGain(bhAfterTaxGain, start, end))
+		tradeHistory += fmt.Sprintf("symbol: %s, total gain%s after tax (annualized):    %5.2f (%5.2f)\n",
+			dlIssue.Symbol, taxCurrency, afterTaxGain, AnnualizedGain(afterTaxGain, start, end))
+	}
 	tradeHistory += fmt.Sprintf("symbol: %s, total gain (annualized):    %5.2f (%5.2f)\n\n",
 		dlIssue.Symbol, gain, AnnualizedGain(gain, start, end))
 	lpf(logh.Info, tradeHistory)
+	return tradeHistory
+}
 
-	return tradeHistory, gain, tradeGain
+// TradeName returns the name of a trade value (LongQuickBuy, LongBuy, Close, ShortSell).
+func TradeName(trade int) string {
+	switch {
+	case trade >= LongQuickBuy:
+		return "long quick buy"
+	case trade == LongBuy:
+		return "long"
+	case trade == Close:
+		return "closed"
+	default:
+		return "short"
+	}
 }
 
 // TradeOnSignal delays delay number of points, then compares signal to the buyLevel and sellLevel,
@@ -540,7 +777,15 @@ func TradeOnSignal(rebuyInputs *TradeOnSignalLongQuickBuyInputs, delay int, sign
 // stopLossDelay keeps a long trade closed for this many points after the stop is triggered.
 // This is to prevent a trade from being closed and then immediately re-opened.
 func TradeAddStop(trade []int, stopLoss float64, stopLossDelay int, dlIssue downloader.Issue) (tradeOut []int) {
+	tradeOut, _ = TradeAddStopExits(trade, stopLoss, stopLossDelay, dlIssue)
+	return tradeOut
+}
+
+// TradeAddStopExits is TradeAddStop, and also returns stopExit, which is true at each point
+// where a stop closed a trade; see TradeLedger.
+func TradeAddStopExits(trade []int, stopLoss float64, stopLossDelay int, dlIssue downloader.Issue) (tradeOut []int, stopExit []bool) {
 	tradeOut = make([]int, len(trade))
+	stopExit = make([]bool, len(trade))
 
 	bestCloseSinceBuy := 0.0
 	stopTriggered := false
@@ -587,8 +832,135 @@ func TradeAddStop(trade []int, stopLoss float64, stopLossDelay int, dlIssue down
 			stopTriggered = true
 			stopTriggeredIndex = i
 			tradeOut[i] = Close
+			stopExit[i] = true
 		}
 	}
 
-	return tradeOut
+	return tradeOut, stopExit
+}
+
+// close sets the gains of t, closed at point closeIndex paying exitCost (a fraction of
+// ExitPrice); the exit price and dates must be set.
+func (t *Trade) close(dlIssue downloader.Issue, fxRate []float64, openFX float64, closeIndex int, exitCost float64) {
+	t.HoldingDays = int(t.ExitDate.Sub(t.EntryDate).Hours() / 24)
+	var costs float64
+	if t.Direction >= LongBuy {
+		cost := holdingCost(dlIssue, t.EntryDate, t.ExitDate)
+		t.HoldingCost = 100 * (1 - cost)
+		costs = (1 - exitCost) / (1 + t.entryCost)
+		t.Gain = t.ExitPrice / t.EntryPrice * cost * costs
+	} else {
+		costs = (1 - t.entryCost) / (1 + exitCost)
+		t.Gain = t.EntryPrice / t.ExitPrice * costs
+	}
+	t.Costs = 100 * (1 - costs)
+	if fxRate != nil {
+		t.BaseGain = fxGain(t.Gain, openFX, fxRate[closeIndex], t.Direction)
+	}
+}
+
+// nextTradingDay returns the date of the trading day after the last bar in dlIssue; the day
+// on which a trade signaled by the last bar is made. Intraday trades are made on the next bar.
+func nextTradingDay(dlIssue downloader.Issue) string {
+	if dlIssue.Interval.Intraday() {
+		return "NEXT BAR"
+	}
+	dates := dlIssue.DatasetAsColumns.Date
+	return downloader.Calendar.NextTradingDay(dates[len(dates)-1]).Format(DateFormat)
+}
+
+// baseFXRate returns the FX rates of dlIssue, or nil if dlIssue was not converted to a base currency.
+func baseFXRate(dlIssue downloader.Issue) []float64 {
+	if dlIssue.BaseCurrency == "" || len(dlIssue.FXRate) != len(dlIssue.DatasetAsColumns.Date) {
+		return nil
+	}
+	return dlIssue.FXRate
+}
+
+// dividendHistory returns the tradeHistory text for the dividends received (long) or paid (short)
+// during a trade; empty when there were none.
+func dividendHistory(tradeDividends float64, trade int) string {
+	if tradeDividends == 0 {
+		return ""
+	}
+	if trade <= ShortSell {
+		return fmt.Sprintf("dividends paid: %6.2f, ", tradeDividends)
+	}
+	return fmt.Sprintf("dividends: %6.2f, ", tradeDividends)
+}
+
+// holdingCost returns the fraction of a long position kept after paying the annual
+// Profile.ExpenseRatio of dlIssue from open to close; 1 when HoldingCost is false or the expense
+// ratio is unknown.
+func holdingCost(dlIssue downloader.Issue, open time.Time, close time.Time) float64 {
+	if !HoldingCost || dlIssue.Profile == nil || dlIssue.Profile.ExpenseRatio <= 0 {
+		return 1
+	}
+	years := close.Sub(open).Hours() / (24 * 365)
+	return math.Pow(1-dlIssue.Profile.ExpenseRatio/100, years)
+}
+
+// pointHoldingCost returns the holdingCost of a long trade opened on open, from prior, or open
+// when later, to date; the holding cost of one point of tradeGain.
+func pointHoldingCost(dlIssue downloader.Issue, open time.Time, prior time.Time, date time.Time) float64 {
+	if prior.Before(open) {
+		prior = open
+	}
+	return holdingCost(dlIssue, prior, date)
+}
+
+// holdingCostHistory returns the holding cost, in percent, for the trade history; empty when
+// there is no cost.
+func holdingCostHistory(cost float64) string {
+	if cost == 0 {
+		return ""
+	}
+	return fmt.Sprintf("holding cost: %5.2f%%, ", cost)
+}
+
+// dividendOn returns the sum of the dividends with an ex-dividend date after the day of
+// dates[i-1], through the day of dates[i]; so weekly and monthly points receive every dividend
+// of the period, and only the first bar of each day receives the dividend of intraday bars.
+// The first point only receives the dividend with an ex-dividend date of dates[0].
+func dividendOn(dividends []dayDividend, dates []time.Time, i int) float64 {
+	day := dates[i].Format(DateFormat)
+	prior := day
+	if i > 0 {
+		prior = dates[i-1].Format(DateFormat)
+	}
+	var sum float64
+	first := sort.Search(len(dividends), func(j int) bool { return dividends[j].day > prior })
+	if i == 0 {
+		first = sort.Search(len(dividends), func(j int) bool { return dividends[j].day >= day })
+	}
+	for j := first; j < len(dividends) && dividends[j].day <= day; j++ {
+		sum += dividends[j].amount
+	}
+	return sum
+}
+
+// fxGain converts the local currency gain of a trade to base currency. Long trades convert
+// base to local currency at openFX and back at closeFX; short trade proceeds are converted to
+// base currency at openFX and the buy is funded at closeFX.
+func fxGain(localGain float64, openFX float64, closeFX float64, trade int) float64 {
+	if trade <= ShortSell {
+		return localGain * openFX / closeFX
+	}
+	return localGain * closeFX / openFX
+}
+
+// dayDividend is the dividend amount of an ex-dividend day, formatted using DateFormat.
+type dayDividend struct {
+	day    string
+	amount float64
+}
+
+// dividendsByDate returns the dividend amounts for dlIssue by ex-dividend day, in day order.
+func dividendsByDate(dlIssue downloader.Issue) []dayDividend {
+	out := make([]dayDividend, 0, len(dlIssue.Dividends))
+	for _, d := range dlIssue.Dividends {
+		out = append(out, dayDividend{day: d.Date.Format(DateFormat), amount: d.Amount})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].day < out[j].day })
+	return out
 }
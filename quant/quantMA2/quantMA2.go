@@ -13,6 +13,7 @@ import (
 	"github.com/paulfdunn/go-helper/logh/v2"
 	"github.com/paulfdunn/go-quantstudio/downloader"
 	"github.com/paulfdunn/go-quantstudio/quant"
+	"github.com/paulfdunn/go-quantstudio/quant/metrics"
 )
 
 type Group struct {
@@ -37,12 +38,16 @@ type QuantMA2 struct {
 	PriceMALow           []float64
 	PriceMALowShort      []float64
 	Results              quant.Results
+	Metrics              metrics.Metrics
 }
 
 var (
 	appName string
 	lp      func(level logh.LoghLevel, v ...interface{})
 	lpf     func(level logh.LoghLevel, format string, v ...interface{})
+
+	// Costs is the cost model of the trades of this strategy; nil for no trading costs.
+	Costs quant.CostModel
 )
 
 func Init(appNameInit string) {
@@ -121,13 +126,20 @@ func UpdateIssue(iss *downloader.Issue, maLengthLF int, maLengthHF int, maShortS
 		lpf(logh.Error, "symbol: %s, %+v", iss.Symbol, err)
 		return Issue{}
 	}
-	tradeMA = quant.TradeAddStop(tradeMA, stopLoss, stopLossDelay, *iss)
+	tradeMA, stopExit := quant.TradeAddStopExits(tradeMA, stopLoss, stopLossDelay, *iss)
 	dir := quant.ConsecutiveDirection(iss.DatasetAsColumns.Close)
 	// dirMA, _ := quant.MA(10, true, quant.IntSliceToFloatSlice(quant.Direction(iss.DatasetAsColumns.Close)))
-	tradeHistory, totalGain, tradeGainVsTime := quant.TradeGain(maLengthLF, tradeMA, *iss)
+	trades, totalGain, tradeGainVsTime := quant.TradeLedger(maLengthLF, tradeMA, stopExit, Costs, *iss)
+	tradeHistory := quant.TradeHistory(maLengthLF, trades, *iss)
+	baseGain := quant.LedgerBaseGain(maLengthLF, trades, totalGain, *iss)
 	annualizedGain := quant.AnnualizedGain(totalGain, issDAC.Date[0], issDAC.Date[len(issDAC.Date)-1])
+	baseAnnualizedGain := quant.AnnualizedGain(baseGain, issDAC.Date[0], issDAC.Date[len(issDAC.Date)-1])
+	afterTaxGain := quant.AfterTaxGain(maLengthLF, trades, *iss)
+	afterTaxAnnualizedGain := quant.AnnualizedGain(afterTaxGain, issDAC.Date[0], issDAC.Date[len(issDAC.Date)-1])
 	results := quant.Results{AnnualizedGain: annualizedGain, TotalGain: totalGain, TradeHistory: tradeHistory,
-		Trade: tradeMA, TradeGainVsTime: tradeGainVsTime}
+		Trade: tradeMA, TradeGainVsTime: tradeGainVsTime, Trades: trades,
+		BaseGain: baseGain, BaseAnnualizedGain: baseAnnualizedGain,
+		AfterTaxGain: afterTaxGain, AfterTaxAnnualizedGain: afterTaxAnnualizedGain}
 	return Issue{DownloaderIssue: iss,
 		QuantsetAsColumns: QuantMA2{
 			Direction: dir,
@@ -136,19 +148,14 @@ func UpdateIssue(iss *downloader.Issue, maLengthLF int, maLengthHF int, maShortS
 			PriceNormalizedHigh:  priceNormalizedHigh, PriceNormalizedLow: priceNormalizedLow,
 			PriceNormalizedOpen: priceNormalizedOpen,
 			PriceMAHigh:         priceMAHf, PriceMALow: priceMALf, PriceMALowShort: shortMA,
-			Results: results,
+			Results: results, Metrics: metrics.New(maLengthLF, results, *iss),
 		}}
 }
 
-func WrappedPlotlyHandler(dlGroupChan chan *downloader.Group, tradingSymbols []string) http.HandlerFunc {
-	// Use a closure to persist the state of these variables between calls. The first call to this
-	// function must always have data in dlGroupChan. Subsequent calls will use the already
-	// downloaded data and only process the single issue and parameters specified in the call. If
-	// the user presses the Download button, new data IS downloaded and put in dlGroupChan, and that
-	// data processed. This complexity allows fast processing without downloading data every call,
-	// but also allows background downloading of (fresh) data and subsequent analysis.
-	var dlGroup *downloader.Group
-	var trdSymbols = tradingSymbols
+// WrappedPlotlyHandler returns a handler that runs the analysis on the single issue and parameters
+// specified in the call, using the current version of groupName in cache. Downloads replace the
+// Group in cache, so each call uses the most recent data without downloading data every call.
+func WrappedPlotlyHandler(cache *downloader.Cache, groupName string, tradingSymbols []string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		urlSymbol := strings.ToLower(r.URL.Query().Get("symbol"))
 		malf := r.URL.Query().Get("maLengthLF")
@@ -191,12 +198,14 @@ func WrappedPlotlyHandler(dlGroupChan chan *downloader.Group, tradingSymbols []s
 		if strings.EqualFold(ema, "true") {
 			emaChecked = true
 		}
-		select {
-		case dlGroup = <-dlGroupChan:
-		default:
-			lp(logh.Debug, "using previously downloaded data")
+		entry, ok := cache.Get(groupName)
+		if !ok {
+			lpf(logh.Warning, "Data for group %s is not loaded.", groupName)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 		}
-		if !slices.Contains(trdSymbols, urlSymbol) {
+		dlGroup := entry.Group
+		if !slices.Contains(tradingSymbols, urlSymbol) {
 			lpf(logh.Warning, "Symbol %s was not found in the symbolCSVList.", urlSymbol)
 			w.WriteHeader(http.StatusNotFound)
 			return
@@ -303,7 +312,7 @@ func plotlyJSON(qIssue Issue, w io.Writer) error {
 			"spikedistance": 50,
 			"hoverdistance": 50,
 			"autosize":      true,
-			"title":         qIssue.DownloaderIssue.Symbol,
+			"title":         qIssue.DownloaderIssue.Description(),
 			"grid": map[string]int{
 				"rows":    1,
 				"columns": 1,
@@ -392,7 +401,10 @@ func plotlyJSON(qIssue Issue, w io.Writer) error {
 			// 	"position":   0.93,
 			// },
 		},
-		"text": qIssue.QuantsetAsColumns.Results.TradeHistory,
+		"text":    qIssue.QuantsetAsColumns.Results.TradeHistory,
+		"trades":  qIssue.QuantsetAsColumns.Results.Trades,
+		"metrics": qIssue.QuantsetAsColumns.Metrics,
+		"meta":    qIssue.DownloaderIssue.Meta,
 	}
 
 	return json.NewEncoder(w).Encode(reply)
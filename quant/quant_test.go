@@ -318,6 +318,347 @@ func Example_sumSlices() {
 	// [111 222 333]
 }
 
+func Example_totalReturn() {
+	issue := downloader.Issue{}
+	issue.DatasetAsColumns.Close = []float64{10.0, 10.0, 9.5, 9.5}
+	issue.DatasetAsColumns.Date = []time.Time{
+		time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 6, 0, 0, 0, 0, time.UTC)}
+	// The price drops by the dividend on the ex-dividend date.
+	issue.Dividends = []downloader.Dividend{{Date: issue.DatasetAsColumns.Date[2], Amount: 0.5}}
+	fmt.Printf("%+v\n", TotalReturn(issue))
+
+	// Weekly points receive the dividends with an ex-dividend date during the week.
+	issue.DatasetAsColumns.Close = []float64{10.0, 10.0, 9.5, 9.0}
+	issue.DatasetAsColumns.Date = []time.Time{
+		time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 17, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 24, 0, 0, 0, 0, time.UTC)}
+	issue.Dividends = []downloader.Dividend{
+		{Date: time.Date(2022, 1, 12, 0, 0, 0, 0, time.UTC), Amount: 0.5},
+		{Date: time.Date(2022, 1, 18, 0, 0, 0, 0, time.UTC), Amount: 0.25},
+		{Date: time.Date(2022, 1, 20, 0, 0, 0, 0, time.UTC), Amount: 0.25}}
+	fmt.Printf("%+v\n", TotalReturn(issue))
+
+	// Output:
+	// [1 1 1 1]
+	// [1 1 1 1]
+}
+
+func Example_tradeGain_cash() {
+	lby := LongBuy
+	cls := Close
+	trade____ := []int{cls, cls, lby, lby, cls, cls, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 11.0, 11.0, 11.0, 11.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 11.0, 11.0, 11.0}
+	for y := 2017; y <= 2023; y++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	CashRate = 5
+	defer func() { CashRate = 0 }()
+	tradeHistory, gain, tradeG := TradeGain(1, trade____, issue)
+	fmt.Print(tradeHistory)
+	fmt.Printf("%5.3f %5.3f\n", gain, tradeG)
+
+	// A downloaded cash series (see downloader.Group.SetCash) is used instead of CashRate.
+	issue.Cash = []float64{1.00, 1.01, 1.02, 1.03, 1.04, 1.05, 1.06}
+	_, gain, _ = TradeGain(1, trade____, issue)
+	fmt.Printf("%5.3f\n", gain)
+
+	// Output:
+	// first trading day: 2017-01-01, last trading day: 2023-01-01
+	// symbol: test, date: 2019-01-01, long buy price:    10.00, date: 2021-01-01, long sell price:    11.00, gain:     1.10
+	// symbol: test, buy/hold gain (annualized):  1.10 ( 1.02)
+	// symbol: test, cash gain while no trade is open (annualized):  1.22 ( 1.04)
+	// symbol: test, total gain (annualized):     1.34 ( 1.06)
+	//
+	// 1.337 [1.000 1.050 1.103 1.213 1.213 1.273 1.337]
+	// 1.144
+}
+
+func Example_tradeGain_dividends() {
+	lby := LongBuy
+	cls := Close
+	trade____ := []int{cls, cls, lby, lby, lby, cls, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.Close = []float64{10.0, 10.0, 10.0, 10.0, 9.5, 9.5, 9.5}
+	for d := 3; d <= 9; d++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC))
+	}
+	issue.Dividends = []downloader.Dividend{{Date: issue.DatasetAsColumns.Date[4], Amount: 0.5}}
+	tradeHistory, _, _ := TradeGain(1, trade____, issue)
+	fmt.Print(tradeHistory)
+
+	// Output:
+	// first trading day: 2022-01-03, last trading day: 2022-01-09
+	// symbol: test, date: 2022-01-05, long buy price:    10.00, date: 2022-01-08, long sell price:    10.00, dividends:   0.50, gain:     1.00
+	// symbol: test, buy/hold gain (annualized):  1.00 ( 1.00)
+	// symbol: test, buy/hold total return (annualized):  1.00 ( 1.00), dividends: 1
+	// symbol: test, total gain (annualized):     1.00 ( 1.00)
+}
+
+func Example_tradeGain_dividendEdges() {
+	lby := LongBuy
+	cls := Close
+	trade____ := []int{cls, cls, lby, lby, lby, cls, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.Close = []float64{10.0, 10.0, 10.0, 9.75, 9.75, 9.75, 9.25}
+	for d := 3; d <= 9; d++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC))
+	}
+	// The trade is bought at the open on the 6th, so the dividend with that ex-dividend date is
+	// not received. It is sold at the open on the 9th, so the dividend with that ex-dividend date
+	// is received.
+	issue.Dividends = []downloader.Dividend{
+		{Date: issue.DatasetAsColumns.Date[3], Amount: 0.25},
+		{Date: issue.DatasetAsColumns.Date[6], Amount: 0.5}}
+	tradeHistory, _, _ := TradeGain(1, trade____, issue)
+	fmt.Print(tradeHistory)
+
+	// Output:
+	// first trading day: 2022-01-03, last trading day: 2022-01-09
+	// symbol: test, date: 2022-01-05, long buy price:    10.00, date: 2022-01-08, long sell price:    10.00, dividends:   0.50, gain:     1.00
+	// symbol: test, buy/hold gain (annualized):  1.00 ( 1.00)
+	// symbol: test, buy/hold total return (annualized):  1.00 ( 1.00), dividends: 2
+	// symbol: test, total gain (annualized):     1.00 ( 1.00)
+}
+
+func Example_tradeGain_fx() {
+	lby := LongBuy
+	cls := Close
+	sht := ShortSell
+	trade____ := []int{cls, lby, lby, cls, cls, sht, cls, cls}
+	issue := downloader.Issue{Symbol: "test", BaseCurrency: "USD"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 11.0, 11.0, 11.0, 11.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 11.0, 11.0, 11.0, 11.0, 10.0}
+	issue.FXRate = []float64{1.0, 1.0, 1.0, 1.2, 1.2, 1.2, 1.2, 1.0}
+	for y := 2016; y <= 2023; y++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	tradeHistory, _, _ := TradeGain(1, trade____, issue)
+	fmt.Print(tradeHistory)
+	_, gain, baseGain, _ := TradeGainBase(1, trade____, issue)
+	fmt.Printf("%5.2f %5.2f\n", gain, baseGain)
+	// Without a base currency, baseGain is the local currency gain.
+	issue.BaseCurrency = ""
+	_, gain, baseGain, _ = TradeGainBase(1, trade____, issue)
+	fmt.Printf("%5.2f %5.2f\n", gain, baseGain)
+
+	// Output:
+	// first trading day: 2016-01-01, last trading day: 2023-01-01
+	// symbol: test, date: 2017-01-01, long buy price:    10.00, date: 2019-01-01, long sell price:    11.00, gain:     1.10, gain (USD):     1.32
+	// symbol: test, date: 2021-01-01, short sell price:    11.00, date: 2022-01-01, short buy price:    10.00, gain:     1.10, gain (USD):     1.32
+	// symbol: test, buy/hold gain (annualized):  1.00 ( 1.00)
+	// symbol: test, buy/hold gain USD (annualized):  1.00 ( 1.00)
+	// symbol: test, total gain USD (annualized):     1.74 ( 1.10)
+	// symbol: test, total gain (annualized):     1.21 ( 1.03)
+	//
+	//  1.21  1.74
+	//  1.21  1.21
+}
+
+func Example_tradeGain_holdingCost() {
+	lby := LongBuy
+	cls := Close
+	sht := ShortSell
+	trade____ := []int{cls, lby, lby, cls, sht, cls, cls}
+	issue := downloader.Issue{Symbol: "test", Profile: &downloader.Profile{ExpenseRatio: 0.5}}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	for y := 2017; y <= 2023; y++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	HoldingCost = true
+	defer func() { HoldingCost = false }()
+	tradeHistory, gain, tradeG := TradeGain(1, trade____, issue)
+	fmt.Print(tradeHistory)
+	// The holding cost is also charged on each point of tradeGain.
+	fmt.Printf("%6.4f %6.4f\n", gain, tradeG)
+
+	// Output:
+	// first trading day: 2017-01-01, last trading day: 2023-01-01
+	// symbol: test, date: 2018-01-01, long buy price:    10.00, date: 2020-01-01, long sell price:    10.00, holding cost:  1.00%, gain:     0.99
+	// symbol: test, date: 2021-01-01, short sell price:    10.00, date: 2022-01-01, short buy price:    10.00, gain:     1.00
+	// symbol: test, buy/hold gain (annualized):  1.00 ( 1.00)
+	// symbol: test, total gain (annualized):     0.99 ( 1.00)
+	//
+	// 0.9900 [1.0000 1.0000 1.0000 0.9900 0.9900 0.9900 0.9900]
+}
+
+func Example_tradeLedger_costs() {
+	lby := LongBuy
+	cls := Close
+	sht := ShortSell
+	trade____ := []int{cls, lby, lby, cls, sht, cls, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.Close = []float64{10.0, 10.0, 10.0, 10.0, 10.0, 10.0, 10.0}
+	issue.DatasetAsColumns.High = []float64{10.0, 10.0, 10.0, 10.0, 10.5, 10.0, 10.0}
+	issue.DatasetAsColumns.Low = []float64{10.0, 10.0, 10.0, 10.0, 9.5, 10.0, 10.0}
+	issue.DatasetAsColumns.Volume = []float64{1000, 1000, 1000, 1000, 1000, 1000, 1000}
+	for d := 3; d <= 9; d++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC))
+	}
+
+	_, err := ParseCosts("commission=1,slippage=random", CostsDefault)
+	fmt.Println(err)
+	costs, _ := ParseCosts("tradeSize=1000,commission=1,perShare=0.01,spreadBps=10,slippage=volatility,slippageFactor=0.1", CostsDefault)
+	// 0.1% commission, 0.1% per share, and 0.05% half spread; plus 1% volatility slippage on the
+	// point with a range.
+	fmt.Printf("%.4f %.4f\n", costs.FillCost(issue, 2, 10), costs.FillCost(issue, 4, 10))
+	trades, _, tradeG := TradeLedger(1, trade____, nil, costs, issue)
+	fmt.Print(TradeHistory(1, trades, issue))
+	fmt.Printf("%5.3f\n", tradeG)
+
+	costs, _ = ParseCosts("tradeSize=1000,slippage=volume,slippageFactor=0.1", CostsDefault)
+	fmt.Printf("%.4f\n", costs.FillCost(issue, 2, 10))
+
+	// Output:
+	// invalid cost: slippage=random, error: invalid slippage: random, valid slippage: [none volume volatility]
+	// 0.0025 0.0125
+	// first trading day: 2022-01-03, last trading day: 2022-01-09
+	// symbol: test, date: 2022-01-04, long buy price:    10.00, date: 2022-01-06, long sell price:    10.00, costs:  1.50%, gain:     0.99
+	// symbol: test, date: 2022-01-07, short sell price:    10.00, date: 2022-01-08, short buy price:    10.00, costs:  0.50%, gain:     1.00
+	// symbol: test, buy/hold gain (annualized):  1.00 ( 1.00)
+	// symbol: test, total gain before costs (annualized):     1.00 ( 1.00), costs:  1.99%
+	// symbol: test, total gain (annualized):     0.98 ( 0.23)
+	//
+	// [1.000 0.998 0.998 0.985 0.983 0.980 0.980]
+	// 0.0316
+}
+
+func Example_tradeLedger_tax() {
+	lby := LongBuy
+	cls := Close
+	sht := ShortSell
+	trade____ := []int{cls, lby, lby, cls, cls, lby, cls, cls, lby, cls, sht, cls, cls}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 20.0, 20.0, 20.0, 20.0, 16.0, 16.0, 16.0, 18.0, 18.0, 20.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 20.0, 20.0, 20.0, 20.0, 16.0, 16.0, 16.0, 18.0, 18.0, 20.0}
+	for _, date := range []string{"2020-01-02", "2020-01-03", "2020-01-06", "2021-03-01", "2021-03-02", "2021-04-01", "2021-04-02",
+		"2021-05-03", "2021-05-10", "2021-05-11", "2021-06-01", "2021-06-02", "2021-07-01"} {
+		d, _ := time.Parse(DateFormat, date)
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, d)
+	}
+
+	_, err := ParseTaxRates("washSale=maybe", TaxRatesDefault)
+	fmt.Println(err)
+	rates, _ := ParseTaxRates("shortTerm=30,longTerm=15,washSale=true", TaxRatesDefault)
+	Tax = &rates
+	defer func() { Tax = nil }()
+	trades, _, _ := TradeLedger(1, trade____, nil, nil, issue)
+	fmt.Print(TradeHistory(1, trades, issue))
+	fmt.Printf("%5.3f\n", AfterTaxGain(1, trades, issue))
+
+	// Output:
+	// invalid tax rate: washSale=maybe, error: strconv.ParseBool: parsing "maybe": invalid syntax
+	// first trading day: 2020-01-02, last trading day: 2021-07-01
+	// symbol: test, date: 2020-01-03, long buy price:    10.00, date: 2021-03-01, long sell price:    20.00, gain:     2.00, long term tax: 15.00%, after-tax gain:     1.85
+	// symbol: test, date: 2021-04-01, long buy price:    20.00, date: 2021-04-02, long sell price:    16.00, gain:     0.80, wash sale
+	// symbol: test, date: 2021-05-10, long buy price:    16.00, date: 2021-05-11, long sell price:    18.00, gain:     1.12, short term tax:  3.75%, after-tax gain:     1.09
+	// symbol: test, date: 2021-06-01, short sell price:    18.00, date: 2021-06-02, short buy price:    20.00, gain:     0.90, short term tax: -3.00%, after-tax gain:     0.93
+	// symbol: test, buy/hold gain (annualized):  2.00 ( 1.59)
+	// symbol: test, buy/hold gain after tax (annualized):  1.85 ( 1.51)
+	// symbol: test, total gain after tax (annualized):     1.50 ( 1.31)
+	// symbol: test, total gain (annualized):     1.62 ( 1.38)
+	//
+	// 1.497
+}
+
+func Example_tradeLedger_taxStillOpen() {
+	lby := LongBuy
+	cls := Close
+	trade____ := []int{cls, lby, lby, lby}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 10.0, 12.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 12.0}
+	for _, date := range []string{"2020-01-02", "2020-01-03", "2020-01-06", "2021-06-01"} {
+		d, _ := time.Parse(DateFormat, date)
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, d)
+	}
+	// The trade still open is taxed as if sold at the last close, the same as buy/hold.
+	rates := TaxRatesDefault
+	Tax = &rates
+	defer func() { Tax = nil }()
+	trades, _, _ := TradeLedger(1, trade____, nil, nil, issue)
+	fmt.Print(TradeHistory(1, trades, issue))
+
+	// Output:
+	// first trading day: 2020-01-02, last trading day: 2021-06-01
+	// symbol: test, date: 2020-01-03, long buy price:    10.00, date: 2021-06-01, long sell price:    12.00, gain:     1.20, long term tax:  4.00%, after-tax gain:     1.16 (TRADE STILL OPEN)
+	// symbol: test, buy/hold gain (annualized):  1.20 ( 1.14)
+	// symbol: test, buy/hold gain after tax (annualized):  1.16 ( 1.11)
+	// symbol: test, total gain after tax (annualized):     1.16 ( 1.11)
+	// symbol: test, total gain (annualized):     1.20 ( 1.14)
+	//
+}
+
+func Example_tradeLedger_entryPending() {
+	lby := LongBuy
+	cls := Close
+	trade____ := []int{cls, lby, lby, cls, cls, lby}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 11.0, 11.0, 11.0, 11.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 11.0, 11.0, 11.0}
+	for y := 2018; y <= 2023; y++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	// The last point signals a long buy; the trade is not priced, so it has no gain.
+	trades, gain, _ := TradeLedger(1, trade____, nil, nil, issue)
+	last := trades[len(trades)-1]
+	fmt.Printf("%d %t %q %5.2f\n", len(trades), last.EntryPending, last.ExitReason, last.Gain)
+	fmt.Printf("%5.2f %5.2f\n", gain, AfterTaxGain(1, trades, issue))
+
+	rates := TaxRatesDefault
+	Tax = &rates
+	defer func() { Tax = nil }()
+	trades, _, _ = TradeLedger(1, trade____, nil, nil, issue)
+	fmt.Printf("%5.3f\n", AfterTaxGain(1, trades, issue))
+
+	// Output:
+	// 2 true ""  0.00
+	//  1.10  1.10
+	// 1.080
+}
+
+func Example_tradeLedger() {
+	lqb := LongQuickBuy
+	lby := LongBuy
+	cls := Close
+	sht := ShortSell
+	trade____ := []int{cls, lby, lby, cls, lqb, cls, sht, sht, cls, lby, lby}
+	stopExit_ := []bool{false, false, false, true, false, false, false, false, false, false, false}
+	issue := downloader.Issue{Symbol: "test"}
+	issue.DatasetAsColumns.AdjClose = []float64{10.0, 10.0, 11.0, 11.0, 11.0, 12.0, 12.0, 11.0, 11.0, 11.0, 12.0}
+	issue.DatasetAsColumns.AdjOpen = []float64{10.0, 10.0, 10.0, 11.0, 10.0, 11.0, 12.0, 12.0, 10.0, 11.0, 11.0}
+	for d := 3; d <= 13; d++ {
+		issue.DatasetAsColumns.Date = append(issue.DatasetAsColumns.Date, time.Date(2022, 1, d, 0, 0, 0, 0, time.UTC))
+	}
+	trades, gain, _ := TradeLedger(1, trade____, stopExit_, nil, issue)
+	for _, t := range trades {
+		fmt.Printf("%s %s entry: %s %5.2f, exit: %s %5.2f, reason: %q, days: %d, gain: %5.2f\n",
+			TradeName(t.Direction), TradeName(t.Entry), t.EntryDate.Format(DateFormat), t.EntryPrice,
+			t.ExitDate.Format(DateFormat), t.ExitPrice, t.ExitReason, t.HoldingDays, t.Gain)
+	}
+	fmt.Printf("%5.2f\n", gain)
+
+	// Output:
+	// long long entry: 2022-01-05 10.00, exit: 2022-01-07 10.00, reason: "stop", days: 2, gain:  1.00
+	// long long quick buy entry: 2022-01-08 11.00, exit: 2022-01-09 12.00, reason: "stop", days: 1, gain:  1.09
+	// short short entry: 2022-01-10 12.00, exit: 2022-01-12 11.00, reason: "signal", days: 2, gain:  1.09
+	// long long entry: 2022-01-13 11.00, exit: 2022-01-13 12.00, reason: "end of data", days: 0, gain:  1.09
+	//  1.30
+}
+
 func Example_tradeGain() {
 	// make columns line up by using lby instead of LongBuy, cls instead of Close, and trade____ instead of trade.
 	lby := LongBuy
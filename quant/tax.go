@@ -0,0 +1,143 @@
+package quant
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/downloader"
+	"github.com/paulfdunn/go-quantstudio/keyValue"
+)
+
+// TaxRates are the tax rates, in percent, on the gains of trades; trades still open at the end
+// of the data are taxed as if sold at the last close, as is buy/hold. Gains are taxed in the base
+// currency when the Issue was converted to a base currency. Gains of trades held
+// more than LongTermDays are taxed at LongTerm, and all other gains, including all short trades,
+// at ShortTerm. Losses are assumed to offset other gains, so they reduce the tax at the same
+// rates; except wash sales, when WashSale is true. Dividends are taxed as part of the gain, as
+// gains use adjusted prices.
+type TaxRates struct {
+	ShortTerm    float64
+	LongTerm     float64
+	LongTermDays int
+	// WashSale, when true, flags losses of long trades followed by a long buy of the Issue
+	// within WashSaleDays of the sale, and the loss does not reduce the tax. (The loss is
+	// actually added to the cost of the new trade, so after-tax gains of wash sales are
+	// conservative.)
+	WashSale     bool
+	WashSaleDays int
+}
+
+// TaxRatesDefault is the base of the TaxRates returned by ParseTaxRates.
+var TaxRatesDefault = TaxRates{ShortTerm: 37, LongTerm: 20, LongTermDays: 365, WashSaleDays: 30}
+
+// Tax, when not nil, is applied to the trades of TradeLedger, and the trade history shows the
+// after-tax gains.
+var Tax *TaxRates
+
+// ParseTaxRates returns base, modified by spec: comma separated key=value pairs; I.E.
+// shortTerm=32,longTerm=15,washSale=true. Keys are shortTerm, longTerm, longTermDays, washSale,
+// and washSaleDays.
+func ParseTaxRates(spec string, base TaxRates) (TaxRates, error) {
+	rates := base
+	err := keyValue.Parse(spec, func(key string, value string) error {
+		var err error
+		switch key {
+		case "shortterm":
+			rates.ShortTerm, err = strconv.ParseFloat(value, 64)
+		case "longterm":
+			rates.LongTerm, err = strconv.ParseFloat(value, 64)
+		case "longtermdays":
+			rates.LongTermDays, err = strconv.Atoi(value)
+		case "washsale":
+			rates.WashSale, err = strconv.ParseBool(value)
+		case "washsaledays":
+			rates.WashSaleDays, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		return err
+	})
+	if err != nil {
+		return base, fmt.Errorf("invalid tax rate: %w", err)
+	}
+	return rates, nil
+}
+
+// AfterTaxGain returns the product of the AfterTaxGain of trades (from TradeLedger on
+// dlIssue), and the CashGain; the return of cash is taxed at the ShortTerm rate. Trades signaled on
+// the last point have not been priced, so they have no gain.
+func AfterTaxGain(delay int, trades []Trade, dlIssue downloader.Issue) float64 {
+	gain := CashGain(delay, trades, dlIssue)
+	if Tax != nil {
+		gain -= Tax.ShortTerm * (gain - 1) / 100
+	}
+	for _, t := range trades {
+		if t.ExitReason == "" {
+			continue
+		}
+		gain *= t.AfterTaxGain
+	}
+	return gain
+}
+
+// applyTax sets the tax and AfterTaxGain of trades using rates; with nil rates, AfterTaxGain is
+// the BaseGain, or Gain when the Issue was not converted to a base currency.
+func applyTax(rates *TaxRates, trades []Trade) {
+	for i := range trades {
+		t := &trades[i]
+		gain := t.Gain
+		if t.BaseGain != 0 {
+			gain = t.BaseGain
+		}
+		t.AfterTaxGain = gain
+		if rates == nil || t.ExitReason == "" {
+			continue
+		}
+		t.LongTerm = t.Direction >= LongBuy && t.HoldingDays > rates.LongTermDays
+		rate := rates.ShortTerm
+		if t.LongTerm {
+			rate = rates.LongTerm
+		}
+		if gain < 1 && rates.WashSale && washSale(trades, i, rates.WashSaleDays) {
+			t.WashSale = true
+			continue
+		}
+		t.Tax = rate * (gain - 1)
+		t.AfterTaxGain = gain - t.Tax/100
+	}
+}
+
+// washSale returns true when trades[i] is a long trade, and a later long trade was opened
+// within days after the exit of trades[i]. Trades do not overlap, so later trades are the only
+// purchases that can replace the shares sold.
+func washSale(trades []Trade, i int, days int) bool {
+	if trades[i].Direction < LongBuy {
+		return false
+	}
+	window := time.Duration(days) * 24 * time.Hour
+	for j, t := range trades {
+		if j <= i || t.Direction < LongBuy {
+			continue
+		}
+		if d := t.EntryDate.Sub(trades[i].ExitDate); d >= 0 && d <= window {
+			return true
+		}
+	}
+	return false
+}
+
+// taxHistory returns the tax of t for the trade history; empty when there is no tax.
+func taxHistory(rates *TaxRates, t Trade) string {
+	switch {
+	case rates == nil:
+		return ""
+	case t.WashSale:
+		return ", wash sale"
+	}
+	term := "short term"
+	if t.LongTerm {
+		term = "long term"
+	}
+	return fmt.Sprintf(", %s tax: %5.2f%%, after-tax gain: %8.2f", term, t.Tax, t.AfterTaxGain)
+}
@@ -0,0 +1,180 @@
+// Package scheduler runs jobs on a schedule, given as a cron expression or relative to the
+// market close of an exchange calendar.
+//
+// Cron expressions have 5 fields: minute (0-59), hour (0-23), day of month (1-31), month (1-12),
+// and day of week (0-7, 0 and 7 are Sunday). Each field is *, a value, a range (a-b), or a list
+// of them (a,b-c), optionally with a step (*/15, 1-5/2). As in cron, when both day of month and
+// day of week are restricted a day matching either runs the job.
+//
+// "@close" runs the job at every market close of the calendar, and "@close+<duration>" (I.E.
+// @close+1h30m) that long after every market close; weekends, holidays, and early closes are
+// handled by the calendar.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/calendar"
+)
+
+// Schedule returns the times to run a job.
+type Schedule interface {
+	// Next returns the first time after t to run the job; the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// cronSchedule is a parsed cron expression; the fields are bit sets of the allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domRestricted and dowRestricted are false for fields that are *.
+	domRestricted, dowRestricted bool
+	location                     *time.Location
+}
+
+// marketCloseSchedule runs offset after every market close of cal.
+type marketCloseSchedule struct {
+	cal    *calendar.Calendar
+	offset time.Duration
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+const (
+	// MarketClose is the prefix of schedules relative to the market close.
+	MarketClose = "@close"
+)
+
+var (
+	fields = []field{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12}, {"day of week", 0, 7}}
+)
+
+// Parse parses expr, a cron expression or a MarketClose schedule. Cron times are in the
+// Location of cal, so schedules follow the exchange across daylight saving time changes.
+func Parse(expr string, cal *calendar.Calendar) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, MarketClose) {
+		offset := time.Duration(0)
+		if rest := strings.TrimPrefix(expr, MarketClose); rest != "" {
+			var err error
+			if offset, err = time.ParseDuration(strings.TrimPrefix(rest, "+")); err != nil || offset < 0 {
+				return nil, fmt.Errorf("invalid schedule: %s, expected %s+<duration>", expr, MarketClose)
+			}
+		}
+		return marketCloseSchedule{cal: cal, offset: offset}, nil
+	}
+
+	values := strings.Fields(expr)
+	if len(values) != len(fields) {
+		return nil, fmt.Errorf("invalid schedule: %s, expected 5 fields (minute hour day-of-month month day-of-week) or %s",
+			expr, MarketClose)
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		var err error
+		if bits[i], err = parseField(values[i], f); err != nil {
+			return nil, fmt.Errorf("invalid schedule: %s, %w", expr, err)
+		}
+	}
+	// Sunday is 0 and 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return cronSchedule{minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domRestricted: values[2] != "*", dowRestricted: values[4] != "*", location: cal.Location}, nil
+}
+
+// Run calls job at every time returned by schedule, until ctx is done. Runs are not
+// concurrent; a run that is missed because job was still running is skipped.
+func Run(ctx context.Context, schedule Schedule, job func(ctx context.Context)) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			job(ctx)
+		}
+	}
+}
+
+func (cs cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(cs.location).Truncate(time.Minute).Add(time.Minute)
+	// Every valid schedule runs within 8 years (I.E. February 29th).
+	limit := t.AddDate(8, 0, 0)
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case cs.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, cs.location)
+		case !cs.dayMatches(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, cs.location)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (cs cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domRestricted && cs.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (mcs marketCloseSchedule) Next(t time.Time) time.Time {
+	return mcs.cal.NextMarketClose(t.Add(-mcs.offset)).Add(mcs.offset)
+}
+
+// parseField returns the bit set of the values allowed by value.
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rng, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step < 1 {
+				return 0, fmt.Errorf("%s: invalid step: %s", f.name, part)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rng != "*" {
+			lowValue, highValue, isRange := strings.Cut(rng, "-")
+			var err1, err2 error
+			low, err1 = strconv.Atoi(lowValue)
+			high, err2 = low, nil
+			if isRange {
+				high, err2 = strconv.Atoi(highValue)
+			} else if hasStep {
+				high = f.max
+			}
+			if err1 != nil || err2 != nil || low < f.min || high > f.max || low > high {
+				return 0, fmt.Errorf("%s: invalid value: %s, allowed values: %d-%d", f.name, part, f.min, f.max)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/paulfdunn/go-quantstudio/calendar"
+)
+
+func ExampleParse() {
+	// Friday 2024-03-08 16:00 New York; daylight saving time starts Sunday 2024-03-10.
+	start := time.Date(2024, time.March, 8, 16, 0, 0, 0, calendar.NYSE.Location)
+	for _, expr := range []string{"30 17 * * 1-5", "*/20 9-10 8 3 *", "0 12 1 * 0", "@close", "@close+1h", "@close+90m",
+		"0 24 * * *", "0 12 *", "@close-1h"} {
+		schedule, err := Parse(expr, calendar.NYSE)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%-16s", expr)
+		t := start
+		for i := 0; i < 3; i++ {
+			t = schedule.Next(t)
+			fmt.Printf(" %s", t.In(calendar.NYSE.Location).Format("Mon 2006-01-02 15:04 MST"))
+		}
+		fmt.Println()
+	}
+
+	// Output:
+	// 30 17 * * 1-5    Fri 2024-03-08 17:30 EST Mon 2024-03-11 17:30 EDT Tue 2024-03-12 17:30 EDT
+	// */20 9-10 8 3 *  Sat 2025-03-08 09:00 EST Sat 2025-03-08 09:20 EST Sat 2025-03-08 09:40 EST
+	// 0 12 1 * 0       Sun 2024-03-10 12:00 EDT Sun 2024-03-17 12:00 EDT Sun 2024-03-24 12:00 EDT
+	// @close           Mon 2024-03-11 16:00 EDT Tue 2024-03-12 16:00 EDT Wed 2024-03-13 16:00 EDT
+	// @close+1h        Fri 2024-03-08 17:00 EST Mon 2024-03-11 17:00 EDT Tue 2024-03-12 17:00 EDT
+	// @close+90m       Fri 2024-03-08 17:30 EST Mon 2024-03-11 17:30 EDT Tue 2024-03-12 17:30 EDT
+	// invalid schedule: 0 24 * * *, hour: invalid value: 24, allowed values: 0-23
+	// invalid schedule: 0 12 *, expected 5 fields (minute hour day-of-month month day-of-week) or @close
+	// invalid schedule: @close-1h, expected @close+<duration>
+}
+
+func ExampleRun() {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	Run(ctx, every10ms{}, func(ctx context.Context) {
+		runs++
+		if runs == 2 {
+			cancel()
+		}
+	})
+	fmt.Println(runs)
+
+	// Output:
+	// 2
+}
+
+// every10ms runs a job 10ms after t.
+type every10ms struct{}
+
+func (every10ms) Next(t time.Time) time.Time {
+	return t.Add(10 * time.Millisecond)
+}
@@ -0,0 +1,213 @@
+// Package universe loads symbol universe files: named groups of symbols (I.E. etfs, bonds,
+// income), with a description, asset class, and expense ratio for each symbol. Files are YAML
+// (.yaml, .yml) or JSON (.json); see default.yaml, which is used when no file is specified.
+package universe
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Universe is the content of a universe file.
+type Universe struct {
+	Groups []Group `json:"groups" yaml:"groups"`
+}
+
+// Group is a named list of symbols.
+type Group struct {
+	Name        string  `json:"name" yaml:"name"`
+	Description string  `json:"description,omitempty" yaml:"description,omitempty"`
+	Symbols     []Entry `json:"symbols" yaml:"symbols"`
+}
+
+// Entry is a symbol and the notes kept about it.
+type Entry struct {
+	Symbol      string `json:"symbol" yaml:"symbol"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// AssetClass is free form; I.E. equity, bond, commodity, rate.
+	AssetClass string `json:"assetClass,omitempty" yaml:"assetClass,omitempty"`
+	// ExpenseRatio is in percent; I.E. 0.09 is 0.09%. Zero when unknown.
+	ExpenseRatio float64 `json:"expenseRatio,omitempty" yaml:"expenseRatio,omitempty"`
+	// AnalysisOnly symbols are downloaded, but only used as inputs for the analysis of the
+	// other symbols; they are not traded. I.E. ^tnx (Treasury Yield 10 Years).
+	AnalysisOnly bool `json:"analysisOnly,omitempty" yaml:"analysisOnly,omitempty"`
+}
+
+//go:embed default.yaml
+var defaultUniverse []byte
+
+// Default returns the universe built into the application.
+func Default() (*Universe, error) {
+	return Decode(defaultUniverse, ".yaml")
+}
+
+// Load reads a universe file; the format is selected by the file extension.
+func Load(filePath string) (*Universe, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	u, err := Decode(b, filepath.Ext(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("universe file: %s, error: %w", filePath, err)
+	}
+	return u, nil
+}
+
+// Decode decodes a universe in the format given by ext (.yaml, .yml, or .json), lowercases
+// the symbols, and validates it.
+func Decode(b []byte, ext string) (*Universe, error) {
+	u := &Universe{}
+	var err error
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, u)
+	case ".json":
+		err = json.Unmarshal(b, u)
+	default:
+		return nil, fmt.Errorf("unsupported universe file extension: %s", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range u.Groups {
+		for j := range u.Groups[i].Symbols {
+			u.Groups[i].Symbols[j].Symbol = strings.ToLower(strings.TrimSpace(u.Groups[i].Symbols[j].Symbol))
+		}
+	}
+	if err := u.validate(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// FromCSV returns a Group named name from a comma separated list of symbols.
+func FromCSV(name string, symbolCSVList string) Group {
+	group := Group{Name: name}
+	for _, symbol := range strings.Split(symbolCSVList, ",") {
+		if symbol = strings.ToLower(strings.TrimSpace(symbol)); symbol != "" {
+			group.Symbols = append(group.Symbols, Entry{Symbol: symbol})
+		}
+	}
+	return group
+}
+
+// Merge returns a Group named name with the symbols of all groups; a symbol in more than one
+// group is only included once, using the first Entry.
+func Merge(name string, groups []Group) Group {
+	merged := Group{Name: name}
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, entry := range group.Symbols {
+			if !seen[entry.Symbol] {
+				seen[entry.Symbol] = true
+				merged.Symbols = append(merged.Symbols, entry)
+			}
+		}
+	}
+	return merged
+}
+
+// Group returns the group named name.
+func (u Universe) Group(name string) (Group, error) {
+	for _, group := range u.Groups {
+		if strings.EqualFold(group.Name, name) {
+			return group, nil
+		}
+	}
+	return Group{}, fmt.Errorf("group: %s not found, groups: %v", name, u.Names())
+}
+
+// Names returns the names of the groups, in file order.
+func (u Universe) Names() []string {
+	names := make([]string, len(u.Groups))
+	for i, group := range u.Groups {
+		names[i] = group.Name
+	}
+	return names
+}
+
+// Select returns the groups named in names, in names order.
+func (u Universe) Select(names []string) ([]Group, error) {
+	groups := make([]Group, 0, len(names))
+	for _, name := range names {
+		group, err := u.Group(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// AllSymbols returns all symbols of grp; the symbols to download.
+func (grp Group) AllSymbols() []string {
+	return grp.symbols(func(Entry) bool { return true })
+}
+
+// AnalysisSymbols returns the AnalysisOnly symbols of grp.
+func (grp Group) AnalysisSymbols() []string {
+	return grp.symbols(func(entry Entry) bool { return entry.AnalysisOnly })
+}
+
+// Entry returns the Entry for symbol.
+func (grp Group) Entry(symbol string) (Entry, bool) {
+	for _, entry := range grp.Symbols {
+		if strings.EqualFold(entry.Symbol, symbol) {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// TradingSymbols returns the symbols of grp that are not AnalysisOnly.
+func (grp Group) TradingSymbols() []string {
+	return grp.symbols(func(entry Entry) bool { return !entry.AnalysisOnly })
+}
+
+func (grp Group) symbols(include func(Entry) bool) []string {
+	var symbols []string
+	for _, entry := range grp.Symbols {
+		if include(entry) {
+			symbols = append(symbols, entry.Symbol)
+		}
+	}
+	return symbols
+}
+
+// validate checks for empty and duplicate group names, and empty and duplicate symbols within
+// a group.
+func (u Universe) validate() error {
+	if len(u.Groups) == 0 {
+		return fmt.Errorf("universe has no groups")
+	}
+	names := make(map[string]bool)
+	for _, group := range u.Groups {
+		name := strings.ToLower(group.Name)
+		if name == "" {
+			return fmt.Errorf("group with no name")
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate group: %s", group.Name)
+		}
+		names[name] = true
+
+		symbols := make(map[string]bool)
+		for _, entry := range group.Symbols {
+			if entry.Symbol == "" {
+				return fmt.Errorf("group: %s, entry with no symbol", group.Name)
+			}
+			if symbols[entry.Symbol] {
+				return fmt.Errorf("group: %s, duplicate symbol: %s", group.Name, entry.Symbol)
+			}
+			symbols[entry.Symbol] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package universe
+
+import (
+	"fmt"
+	"strings"
+)
+
+func ExampleDefault() {
+	u, err := Default()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(u.Names())
+	groups, _ := u.Select([]string{"etfs", "bonds", "income"})
+	merged := Merge("ETFs", groups)
+	fmt.Println(strings.Join(merged.AllSymbols(), ","))
+	entry, _ := merged.Entry("spy")
+	fmt.Printf("%s %s %.2f%%\n", entry.Description, entry.AssetClass, entry.ExpenseRatio)
+
+	// Output:
+	// [etfs bonds income etrade-income]
+	// ^tnx,dia,iau,idev,iefa,intf,psq,qqq,qqqm,rsp,spy,vgt,vt,ddm,qld,sso,tqqq,flot,stip,vcit,vcsh,emb,emhy,bnd,shyg,hdv,schd,vym,hdef
+	// SPDR S&P 500 ETF Trust equity 0.09%
+}
+
+func ExampleDecode() {
+	yamlFile := `
+groups:
+  - name: rates
+    symbols:
+      - symbol: ^TNX
+        analysisOnly: true
+      - symbol: TLT
+        assetClass: bond
+`
+	u, err := Decode([]byte(yamlFile), ".yaml")
+	fmt.Println(err)
+	rates, _ := u.Group("Rates")
+	fmt.Println(rates.TradingSymbols(), rates.AnalysisSymbols())
+
+	jsonFile := `{"groups": [{"name": "a", "symbols": [{"symbol": "spy"}, {"symbol": "SPY"}]}]}`
+	_, err = Decode([]byte(jsonFile), ".json")
+	fmt.Println(err)
+	_, err = u.Select([]string{"rates", "stocks"})
+	fmt.Println(err)
+	fmt.Println(FromCSV("test", " DIA, qqq,,").AllSymbols())
+
+	// Output:
+	// <nil>
+	// [tlt] [^tnx]
+	// group: a, duplicate symbol: spy
+	// group: stocks not found, groups: [rates]
+	// [dia qqq]
+}